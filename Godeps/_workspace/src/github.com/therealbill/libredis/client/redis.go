@@ -57,6 +57,9 @@
 //     MaxIdle:  10
 //   }
 //
+// Servers listening on a tls-port are dialed by setting TLS:
+//   config.TLS = &TLSOptions{CACertFile: "/etc/redis/ca.crt"}
+//
 // Try a redis command is simple too, let's do GET/SET:
 //  err := client.Set("key", "value", 0, 0, false, false)
 //  value, err := client.Get("key")
@@ -83,9 +86,12 @@ package client
 import (
 	"bufio"
 	"container/list"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net"
 	"net/url"
 	"reflect"
@@ -196,6 +202,21 @@ func (c *connection) SendCommand(args ...interface{}) error {
 	return nil
 }
 
+// command sends a command and receives its reply, failing if the exchange
+// takes longer than timeout. A zero timeout waits forever.
+func (c *connection) command(timeout time.Duration, args ...interface{}) (*Reply, error) {
+	if timeout > 0 {
+		if err := c.Conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+			return nil, err
+		}
+		defer c.Conn.SetDeadline(time.Time{})
+	}
+	if err := c.SendCommand(args...); err != nil {
+		return nil, err
+	}
+	return c.RecvReply()
+}
+
 func (c *connection) RecvReply() (*Reply, error) {
 	line, err := c.Reader.ReadBytes('\n')
 	if err != nil {
@@ -328,7 +349,9 @@ type Redis struct {
 	address  string
 	db       int
 	password string
+	username string
 	timeout  time.Duration
+	tls      *tls.Config
 	pool     *connPool
 }
 
@@ -338,69 +361,68 @@ func (r *Redis) GetName() string {
 }
 
 // ExecuteCommand send any raw redis command and receive reply from redis server
+// The exchange is bounded by the client's timeout. A connection which fails
+// is closed rather than returned to the pool, so a late reply can not be
+// taken for the reply to a later command.
 func (r *Redis) ExecuteCommand(args ...interface{}) (*Reply, error) {
 	c, err := r.pool.Get()
 	if err != nil {
 		return nil, err
 	}
-	defer func() { r.pool.Put(c) }()
-	err = c.SendCommand(args...)
-	if err != nil {
-		if err != io.EOF {
-			return nil, err
-		}
+	rp, err := c.command(r.timeout, args...)
+	if err == io.EOF {
+		// An idle pooled connection was closed by the server; retry once
+		// on a fresh one.
+		c.Conn.Close()
 		c, err = r.pool.Get()
 		if err != nil {
 			return nil, err
 		}
-		if err = c.SendCommand(args...); err != nil {
-			return nil, err
-		}
+		rp, err = c.command(r.timeout, args...)
 	}
-	rp, err := c.RecvReply()
 	if err != nil {
-		if err != io.EOF {
-			return nil, err
-		}
-		c, err = r.pool.Get()
-		if err != nil {
-			return nil, err
-		}
-		if err = c.SendCommand(args...); err != nil {
-			return nil, err
-		}
-		return c.RecvReply()
+		c.Conn.Close()
+		return nil, err
 	}
-	return rp, err
+	r.pool.Put(c)
+	return rp, nil
 }
 
 func (r *Redis) dialConnection() (*connection, error) {
-	conn, err := net.DialTimeout(r.network, r.address, r.timeout)
+	var conn net.Conn
+	var err error
+	if r.tls != nil {
+		conn, err = tls.DialWithDialer(&net.Dialer{Timeout: r.timeout}, r.network, r.address, r.tls)
+	} else {
+		conn, err = net.DialTimeout(r.network, r.address, r.timeout)
+	}
 	if err != nil {
 		return nil, err
 	}
 	c := &connection{conn, bufio.NewReader(conn)}
 	if r.password != "" {
-		if err := c.SendCommand("AUTH", r.password); err != nil {
-			return nil, err
+		args := []interface{}{"AUTH", r.password}
+		if r.username != "" {
+			args = []interface{}{"AUTH", r.username, r.password}
 		}
-		rp, err := c.RecvReply()
+		rp, err := c.command(r.timeout, args...)
 		if err != nil {
+			conn.Close()
 			return nil, err
 		}
 		if rp.Type == ErrorReply {
+			conn.Close()
 			return nil, errors.New(rp.Error)
 		}
 	}
 	if r.db > 0 {
-		if err := c.SendCommand("SELECT", r.db); err != nil {
-			return nil, err
-		}
-		rp, err := c.RecvReply()
+		rp, err := c.command(r.timeout, "SELECT", r.db)
 		if err != nil {
+			conn.Close()
 			return nil, err
 		}
 		if rp.Type == ErrorReply {
+			conn.Close()
 			return nil, errors.New(rp.Error)
 		}
 	}
@@ -437,6 +459,53 @@ type DialConfig struct {
 	Password string
 	Timeout  time.Duration
 	MaxIdle  int
+	// Username, when set along with Password, authenticates as that ACL
+	// user (Redis 6 and later) rather than the default user.
+	Username string
+	// TLS, when set, makes every connection over TLS.
+	TLS *TLSOptions
+}
+
+// TLSOptions configure a TLS connection to a server started with tls-port.
+type TLSOptions struct {
+	// CACertFile is a PEM bundle of the CAs trusted to sign the server's
+	// certificate. The system roots are used when it is empty.
+	CACertFile string
+	// CertFile and KeyFile are the PEM client certificate and key, for
+	// servers with tls-auth-clients.
+	CertFile string
+	KeyFile  string
+	// ServerName is checked against the server's certificate instead of the
+	// host being dialed.
+	ServerName string
+	// InsecureSkipVerify accepts any server certificate.
+	InsecureSkipVerify bool
+}
+
+// Config loads the files named by the options into a tls.Config.
+func (o *TLSOptions) Config() (*tls.Config, error) {
+	cfg := &tls.Config{
+		ServerName:         o.ServerName,
+		InsecureSkipVerify: o.InsecureSkipVerify,
+	}
+	if o.CACertFile != "" {
+		pem, err := ioutil.ReadFile(o.CACertFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.CACertFile)
+		}
+	}
+	if o.CertFile != "" || o.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
 }
 
 // Dial new a redis client with just a Host and port
@@ -487,8 +556,16 @@ func DialWithConfig(cfg *DialConfig) (*Redis, error) {
 		address:  cfg.Address,
 		db:       cfg.Database,
 		password: cfg.Password,
+		username: cfg.Username,
 		timeout:  cfg.Timeout,
 	}
+	if cfg.TLS != nil {
+		var err error
+		r.tls, err = cfg.TLS.Config()
+		if err != nil {
+			return nil, err
+		}
+	}
 	r.pool = &connPool{
 		MaxIdle: cfg.MaxIdle,
 		Dial:    r.dialConnection,
@@ -526,7 +603,7 @@ func DialURL(rawurl string) (*Redis, error) {
 	if err != nil {
 		return nil, err
 	}
-	return DialWithConfig(&DialConfig{ul.Scheme, ul.Host, db, password, timeout, maxidle})
+	return DialWithConfig(&DialConfig{Network: ul.Scheme, Address: ul.Host, Database: db, Password: password, Timeout: timeout, MaxIdle: maxidle})
 }
 
 // Reply Type: Status, Integer, Bulk, Multi Bulk
@@ -193,20 +193,32 @@ func (r *Redis) SentinelSlaves(podname string) (slaves []SlaveInfo, err error) {
 // This is used to add pods to the sentinel configuration
 func (r *Redis) SentinelMonitor(podname string, ip string, port int, quorum int) (bool, error) {
 	res, err := r.ExecuteCommand("SENTINEL", "MONITOR", podname, ip, port, quorum)
-	ok, _ := res.BoolValue()
-	return ok, err
+	if err != nil {
+		return false, err
+	}
+	err = res.OKValue()
+	return err == nil, err
 }
 
 // SentinelRemove executes the SENTINEL REMOVE command on the server
 // This is used to remove pods to the sentinel configuration
 func (r *Redis) SentinelRemove(podname string) (bool, error) {
 	res, err := r.ExecuteCommand("SENTINEL", "REMOVE", podname)
-	ok, _ := res.BoolValue()
-	return ok, err
+	if err != nil {
+		return false, err
+	}
+	err = res.OKValue()
+	return err == nil, err
 }
 
+// SentinelReset executes the SENTINEL RESET command on the server
+// This clears the known slaves and sentinels of pods matching the pattern
 func (r *Redis) SentinelReset(podname string) error {
-	_, err := r.ExecuteCommand("SENTINEL", "RESER", podname)
+	res, err := r.ExecuteCommand("SENTINEL", "RESET", podname)
+	if err != nil {
+		return err
+	}
+	_, err = res.IntegerValue()
 	return err
 }
 
@@ -227,8 +239,11 @@ func (r *Redis) SentinelSetInt(podname string, skey string, sval int) error {
 // SentinelSetPass will set the value to be used in the AUTH command for a
 // given pod
 func (r *Redis) SentinelSetPass(podname string, password string) error {
-	_, err := r.ExecuteCommand("SENTINEL", "SET", podname, "AUTHPASS", password)
-	return err
+	res, err := r.ExecuteCommand("SENTINEL", "SET", podname, "AUTH-PASS", password)
+	if err != nil {
+		return err
+	}
+	return res.OKValue()
 }
 
 // SentinelSentinels returns the list of known Sentinels
@@ -4,7 +4,7 @@ import "testing"
 
 func init() {
 	address = "127.0.0.1:6379"
-	client, err := DialWithConfig(&DialConfig{network, address, db, password, timeout, maxidle})
+	client, err := DialWithConfig(&DialConfig{Network: network, Address: address, Database: db, Password: password, Timeout: timeout, MaxIdle: maxidle})
 	if err != nil {
 		panic(err)
 	}
@@ -182,7 +182,7 @@ func (r *Redis) Info() (sinfo info.RedisInfoAll, err error) {
 	if err != nil {
 		return
 	}
-	strval, _ := rp.StringValue()
+	strval, err := rp.StringValue()
 	if err != nil {
 		return
 	}
@@ -198,7 +198,7 @@ func (r *Redis) SentinelInfo() (sinfo info.RedisInfoAll, err error) {
 	if err != nil {
 		return
 	}
-	strval, _ := rp.StringValue()
+	strval, err := rp.StringValue()
 	if err != nil {
 		return
 	}
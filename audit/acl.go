@@ -0,0 +1,220 @@
+package audit
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/therealbill/libredis/client"
+)
+
+// sentinelCommands are the commands Sentinel sends to a master, with the ACL
+// categories each belongs to. A subcommand is named command|subcommand, as
+// ACL rules name it.
+var sentinelCommands = []struct {
+	name       string
+	categories []string
+}{
+	{"ping", []string{"fast", "connection"}},
+	{"info", []string{"slow", "dangerous"}},
+	{"role", []string{"admin", "fast", "dangerous"}},
+	{"subscribe", []string{"pubsub", "slow"}},
+	{"publish", []string{"pubsub", "fast"}},
+	{"multi", []string{"fast", "transaction"}},
+	{"exec", []string{"slow", "transaction"}},
+	{"slaveof", []string{"admin", "slow", "dangerous"}},
+	{"config|rewrite", []string{"admin", "slow", "dangerous"}},
+	{"client|setname", []string{"slow", "connection"}},
+	{"client|kill", []string{"admin", "slow", "dangerous", "connection"}},
+	{"script|kill", []string{"slow", "scripting"}},
+}
+
+// sentinelHelloChannel is the channel sentinels use to discover each other
+// through the master.
+const sentinelHelloChannel = "__sentinel__:hello"
+
+// aclUser is the part of an ACL GETUSER reply the audit needs.
+type aclUser struct {
+	flags    []string
+	commands []string
+	channels []string
+	// channelRules is set when the server reports channel rules at all;
+	// before Redis 6.2 every user may use every channel.
+	channelRules bool
+}
+
+// checkACL confirms, on a connection authenticated with the pod's
+// credentials, that the master knows the connection as the pod's auth-user
+// and that the user may run everything Sentinel sends. A master which can
+// not show the user's rules, such as one before Redis 6 or one denying the
+// user ACL GETUSER, leaves the permissions unverified.
+func (pc *SentinelPodConfig) checkACL(conn *client.Redis) {
+	whoami, err := aclCommand(conn, "WHOAMI")
+	if err != nil {
+		pc.Master.ACLError = err.Error()
+		return
+	}
+	pc.Master.ACLUser, err = whoami.StringValue()
+	if err != nil {
+		pc.Master.ACLError = err.Error()
+		return
+	}
+	rp, err := aclCommand(conn, "GETUSER", pc.AuthUser)
+	if err != nil {
+		pc.Master.ACLError = err.Error()
+		return
+	}
+	if rp.Type == client.BulkReply && rp.Bulk == nil {
+		pc.Master.MissingPermissions = []string{fmt.Sprintf("user %s does not exist", pc.AuthUser)}
+		return
+	}
+	user, err := parseACLUser(rp)
+	if err != nil {
+		pc.Master.ACLError = err.Error()
+		return
+	}
+	pc.Master.MissingPermissions = user.missingPermissions()
+}
+
+// aclCommand runs an ACL subcommand, returning error replies as errors.
+func aclCommand(conn *client.Redis, args ...interface{}) (*client.Reply, error) {
+	rp, err := conn.ExecuteCommand(append([]interface{}{"ACL"}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	if rp.Type == client.ErrorReply {
+		return nil, errors.New(rp.Error)
+	}
+	return rp, nil
+}
+
+// parseACLUser reads an ACL GETUSER reply. Redis 6.2 reports channels as a
+// list of patterns while later versions report them as a rule string, so
+// both forms are flattened into the words they contain.
+func parseACLUser(rp *client.Reply) (user aclUser, err error) {
+	fields, err := rp.MultiValue()
+	if err != nil {
+		return user, err
+	}
+	for i := 0; i+1 < len(fields); i += 2 {
+		key, err := fields[i].StringValue()
+		if err != nil {
+			return user, err
+		}
+		words := replyWords(fields[i+1])
+		switch key {
+		case "flags":
+			user.flags = words
+		case "commands":
+			user.commands = words
+		case "channels":
+			user.channels = words
+			user.channelRules = true
+		}
+	}
+	return user, nil
+}
+
+// replyWords splits a bulk reply, or every bulk reply in a multi bulk reply,
+// into words.
+func replyWords(rp *client.Reply) (words []string) {
+	switch rp.Type {
+	case client.BulkReply:
+		words = strings.Fields(string(rp.Bulk))
+	case client.MultiReply:
+		for _, item := range rp.Multi {
+			words = append(words, replyWords(item)...)
+		}
+	}
+	return
+}
+
+// missingPermissions returns the commands and channels Sentinel needs which
+// the user's rules do not allow.
+func (u aclUser) missingPermissions() (missing []string) {
+	for _, flag := range u.flags {
+		if flag == "off" {
+			return []string{"user is disabled"}
+		}
+	}
+	for _, cmd := range sentinelCommands {
+		if !u.allowsCommand(cmd.name, cmd.categories) {
+			missing = append(missing, cmd.name)
+		}
+	}
+	if !u.allowsChannel(sentinelHelloChannel) {
+		missing = append(missing, "&"+sentinelHelloChannel)
+	}
+	return
+}
+
+// allowsCommand applies the user's command rules in order, as Redis does,
+// to the command name in the given categories. A rule for a whole command
+// also covers its subcommands.
+func (u aclUser) allowsCommand(name string, categories []string) bool {
+	base := strings.SplitN(name, "|", 2)[0]
+	allowed := false
+	for _, rule := range u.commands {
+		switch rule {
+		case "allcommands":
+			allowed = true
+			continue
+		case "nocommands":
+			allowed = false
+			continue
+		}
+		if len(rule) < 2 || (rule[0] != '+' && rule[0] != '-') {
+			continue
+		}
+		grant, target := rule[0] == '+', strings.ToLower(rule[1:])
+		if strings.HasPrefix(target, "@") {
+			if target == "@all" || inCategories(target[1:], categories) {
+				allowed = grant
+			}
+		} else if target == name || target == base {
+			allowed = grant
+		}
+	}
+	return allowed
+}
+
+func inCategories(category string, categories []string) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsChannel reports whether the user's channel rules permit channel.
+func (u aclUser) allowsChannel(channel string) bool {
+	if !u.channelRules {
+		return true
+	}
+	for _, flag := range u.flags {
+		if flag == "allchannels" {
+			return true
+		}
+	}
+	allowed := false
+	for _, rule := range u.channels {
+		switch {
+		case rule == "allchannels":
+			allowed = true
+		case rule == "resetchannels":
+			allowed = false
+		case strings.HasPrefix(rule, "&"):
+			if matched, _ := path.Match(rule[1:], channel); matched {
+				allowed = true
+			}
+		default:
+			// Redis 6.2 lists the bare patterns.
+			if matched, _ := path.Match(rule, channel); matched {
+				allowed = true
+			}
+		}
+	}
+	return allowed
+}
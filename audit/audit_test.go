@@ -0,0 +1,1144 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/therealbill/audit-sentinel-config/redistest"
+	"github.com/therealbill/audit-sentinel-config/sentinelconf"
+	"github.com/therealbill/libredis/client"
+)
+
+// fleet is the set of fake servers the fixtures are audited against. Each
+// field is a "host port" pair as sentinel.conf directives take them.
+type fleet struct {
+	Master         string
+	Slave          string
+	Slave2         string
+	Sentinel1      string
+	Sentinel2      string
+	Sentinel3      string
+	EmptySentinel  string
+	SkewedSentinel string
+	// HostnameSentinel names the master localhost rather than by IP.
+	HostnameSentinel string
+	// ProtectedSentinel requires the password "sentinelsecret".
+	ProtectedSentinel string
+	Dead              string
+	Dead2             string
+	// Silent accepts connections but never replies.
+	Silent string
+	// CACertFile is the CA bundle of a TLS fleet's servers, not an address.
+	CACertFile string
+
+	servers   []*redistest.Server
+	listeners []net.Listener
+}
+
+func hostPort(s *redistest.Server) string {
+	return fmt.Sprintf("%s %d", s.Host(), s.Port())
+}
+
+// deadAddress returns a localhost address nothing listens on.
+func deadAddress(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().(*net.TCPAddr)
+	ln.Close()
+	return fmt.Sprintf("%s %d", addr.IP, addr.Port)
+}
+
+// silentAddress returns a localhost address which accepts connections and
+// never replies on them. Closing the listener closes the connections.
+func silentAddress(t *testing.T) (string, net.Listener) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		var conns []net.Conn
+		defer func() {
+			for _, c := range conns {
+				c.Close()
+			}
+		}()
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, c)
+		}
+	}()
+	addr := ln.Addr().(*net.TCPAddr)
+	return fmt.Sprintf("%s %d", addr.IP, addr.Port), ln
+}
+
+// newFleet starts a master with one replica connected, a second replica, and
+// sentinels which agree with, know nothing of, or disagree with the pod in
+// good.conf. The master and replicas have the ACL user "sentinel", with the
+// permissions Sentinel needs, and "limited", with too few.
+func newFleet(t *testing.T) *fleet {
+	return startFleet(t, redistest.NewServer)
+}
+
+// newTLSFleet starts the same fleet with servers which only accept TLS.
+func newTLSFleet(t *testing.T) *fleet {
+	f := startFleet(t, redistest.NewTLSServer)
+	pem, err := redistest.CACert()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.CACertFile = filepath.Join(t.TempDir(), "ca.crt")
+	if err := ioutil.WriteFile(f.CACertFile, pem, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func startFleet(t *testing.T, newServer func() (*redistest.Server, error)) *fleet {
+	f := &fleet{}
+	start := func() *redistest.Server {
+		s, err := newServer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.servers = append(f.servers, s)
+		return s
+	}
+	addUsers := func(s *redistest.Server) {
+		s.AddUser("sentinel", "-@all +@pubsub +ping +info +role +multi +exec +slaveof +config|rewrite +client|setname +client|kill +script|kill", "&__sentinel__:hello")
+		s.AddUser("limited", "-@all +ping +info", "resetchannels")
+	}
+	master := start()
+	master.SetPassword("secret")
+	addUsers(master)
+	master.SetInfo("Replication", "role", "master", "connected_slaves", "1")
+	master.SetInfo("Memory", "used_memory", "1048576")
+	f.Master = hostPort(master)
+
+	replica := func() string {
+		s := start()
+		s.SetPassword("secret")
+		addUsers(s)
+		s.SetInfo("Replication", "role", "slave", "master_host", master.Host(),
+			"master_port", strconv.Itoa(master.Port()), "master_link_status", "up")
+		return hostPort(s)
+	}
+	f.Slave = replica()
+	f.Slave2 = replica()
+
+	view := func(port, quorum, infoRefresh int) map[string]string {
+		return map[string]string{
+			"name":         "mymaster",
+			"ip":           master.Host(),
+			"port":         strconv.Itoa(port),
+			"quorum":       strconv.Itoa(quorum),
+			"flags":        "master",
+			"info-refresh": strconv.Itoa(infoRefresh),
+		}
+	}
+	sentinel := func(views ...map[string]string) string {
+		s := start()
+		for _, v := range views {
+			s.AddMaster(v)
+		}
+		return hostPort(s)
+	}
+	f.Sentinel1 = sentinel(view(master.Port(), 2, 1000))
+	f.Sentinel2 = sentinel(view(master.Port(), 2, 1000))
+	f.Sentinel3 = sentinel(view(master.Port(), 2, 1000))
+	f.EmptySentinel = sentinel()
+	f.SkewedSentinel = sentinel(view(master.Port()+1, 3, 600000))
+	byHostname := view(master.Port(), 2, 1000)
+	byHostname["ip"] = "localhost"
+	f.HostnameSentinel = sentinel(byHostname)
+	f.ProtectedSentinel = sentinel(view(master.Port(), 2, 1000))
+	f.servers[len(f.servers)-1].SetPassword("sentinelsecret")
+	f.Dead = deadAddress(t)
+	f.Dead2 = deadAddress(t)
+	var silent net.Listener
+	f.Silent, silent = silentAddress(t)
+	f.listeners = append(f.listeners, silent)
+	return f
+}
+
+// server returns the fake server at addr, a "host port" pair.
+func (f *fleet) server(addr string) *redistest.Server {
+	for _, s := range f.servers {
+		if hostPort(s) == addr {
+			return s
+		}
+	}
+	return nil
+}
+
+func (f *fleet) Close() {
+	for _, s := range f.servers {
+		s.Close()
+	}
+	for _, ln := range f.listeners {
+		ln.Close()
+	}
+}
+
+// fakeResolver resolves the hostnames in the fixtures without DNS.
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+// testResolver knows localhost, which the fake servers also answer as, and
+// a round-robin name; every other hostname fails to resolve.
+var testResolver = fakeResolver{
+	"localhost":       {"127.0.0.1"},
+	"roundrobin.test": {"127.0.0.1", "127.0.0.2"},
+}
+
+// render fills the fleet's addresses into the fixture. {{port .Sentinel1}}
+// and the like give just the port of an address, for fixtures which name
+// the server by hostname.
+func (f *fleet) render(t *testing.T, fixture string) Source {
+	path := filepath.Join("testdata", fixture)
+	tmpl, err := template.New(fixture).Funcs(template.FuncMap{
+		"port": func(addr string) string { return addr[strings.LastIndex(addr, " ")+1:] },
+	}).ParseFiles(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b bytes.Buffer
+	err = tmpl.Execute(&b, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return BytesSource{Name: path, Content: b.Bytes()}
+}
+
+// fixtureTests maps each fixture to the issue it is for, and to the other
+// issues it can not help raising: also lists those, so a fixture which starts
+// raising anything else fails the test.
+var fixtureTests = []struct {
+	fixture string
+	policy  string
+	want    []ConfigIssue
+	also    []ConfigIssue
+}{
+	// Two sentinels are also an even number.
+	{"not-enough-sentinels.conf", "", []ConfigIssue{NOTENOUGHSENTINELS}, []ConfigIssue{EVENSENTINELS}},
+	{"no-quorum.conf", "", []ConfigIssue{NOQUORUM, HASINVALIDSENTINELS}, nil},
+	// The master has a replica connected which the config does not know.
+	{"no-slaves.conf", "", []ConfigIssue{NOSLAVES}, []ConfigIssue{SLAVECOUNTMISMATCH}},
+	{"no-valid-slaves.conf", "", []ConfigIssue{NOVALIDSLAVES}, nil},
+	{"invalid-sentinels.conf", "", []ConfigIssue{HASINVALIDSENTINELS}, nil},
+	// The duplicate pod has no slaves or known sentinels of its own.
+	{"duplicate-master-ip.conf", "", []ConfigIssue{DUPLICATEMASTERIP},
+		[]ConfigIssue{NOTENOUGHSENTINELS, NOQUORUM, NOSLAVES, SLAVECOUNTMISMATCH, QUORUMTOOHIGH}},
+	// The second pod's master is a replica, and has no known sentinels. It
+	// shares the first master's host but not its port, so it is not a
+	// duplicate master.
+	{"duplicate-slave-ip.conf", "", []ConfigIssue{DUPLICATESLAVEIP},
+		[]ConfigIssue{NOTENOUGHSENTINELS, NOQUORUM, NOVALIDSLAVES, MASTERISSLAVE, QUORUMTOOHIGH}},
+	{"pod-missing-on-sentinel.conf", "", []ConfigIssue{PODMISSINGONSENTINEL}, nil},
+	{"skewed-sentinel.conf", "", []ConfigIssue{MASTERMISMATCH, QUORUMMISMATCH, AUTHMISMATCH}, nil},
+	// The sentinels and the slave still name the live master.
+	{"master-unreachable.conf", "", []ConfigIssue{MASTERUNREACHABLE}, []ConfigIssue{NOVALIDSLAVES, MASTERMISMATCH}},
+	// The slave is checked with the same wrong auth-pass.
+	{"master-auth-failed.conf", "", []ConfigIssue{MASTERAUTHFAILED}, []ConfigIssue{NOVALIDSLAVES}},
+	// The sentinels name the real master, and the replica has no slaves.
+	{"master-is-slave.conf", "", []ConfigIssue{MASTERISSLAVE}, []ConfigIssue{NOSLAVES, MASTERMISMATCH}},
+	{"slave-count-mismatch.conf", "", []ConfigIssue{SLAVECOUNTMISMATCH}, nil},
+	{"down-after-too-low.conf", "", []ConfigIssue{DOWNAFTERTOOLOW}, nil},
+	{"down-after-too-high.conf", "", []ConfigIssue{DOWNAFTERTOOHIGH}, nil},
+	{"failover-timeout-too-short.conf", "", []ConfigIssue{FAILOVERTIMEOUTTOOSHORT}, nil},
+	// Only one of the two known slaves is connected to the master.
+	{"parallel-syncs-all-slaves.conf", "", []ConfigIssue{PARALLELSYNCSALLSLAVES}, []ConfigIssue{SLAVECOUNTMISMATCH}},
+	{"good.conf", `{"DownAfter": 10000}`, []ConfigIssue{TIMINGPOLICYMISMATCH}, nil},
+	// The master and slave require the missing password.
+	{"no-auth-pass.conf", `{"RequireAuth": true}`, []ConfigIssue{NOAUTHPASS}, []ConfigIssue{NOVALIDSLAVES, MASTERAUTHFAILED}},
+	// The other sentinels keep their quorum of 2.
+	{"quorum-of-all.conf", `{"QuorumFormula": "majority"}`, []ConfigIssue{QUORUMFORMULA}, []ConfigIssue{QUORUMMISMATCH}},
+	{"quorum-too-high.conf", "", []ConfigIssue{QUORUMTOOHIGH}, []ConfigIssue{NOQUORUM, QUORUMMISMATCH}},
+	{"quorum-below-majority.conf", "", []ConfigIssue{QUORUMBELOWMAJORITY}, []ConfigIssue{QUORUMMISMATCH}},
+	// Four sentinels need a quorum of 3 for a majority.
+	{"even-sentinels.conf", "", []ConfigIssue{EVENSENTINELS}, []ConfigIssue{QUORUMBELOWMAJORITY}},
+	{"sentinel-auth-failed.conf", "", []ConfigIssue{SENTINELAUTHFAILED}, nil},
+	{"acl-permissions.conf", "", []ConfigIssue{ACLPERMISSIONS}, nil},
+	// The slaves which do not resolve to one address are not connected.
+	{"hostname-resolution.conf", "", []ConfigIssue{HOSTNAMERESOLUTION}, []ConfigIssue{SLAVECOUNTMISMATCH}},
+	// The duplicate is counted, making four sentinels.
+	{"duplicate-sentinel.conf", "", []ConfigIssue{DUPLICATESENTINEL}, []ConfigIssue{QUORUMBELOWMAJORITY, EVENSENTINELS}},
+	{"duplicate-master-hostname.conf", "", []ConfigIssue{DUPLICATEMASTERIP},
+		[]ConfigIssue{NOTENOUGHSENTINELS, NOQUORUM, NOSLAVES, SLAVECOUNTMISMATCH, QUORUMTOOHIGH}},
+	// The remote sentinel is a documentation address nothing answers on.
+	{"local-address.conf", "", []ConfigIssue{LOCALADDRESS}, []ConfigIssue{HASINVALIDSENTINELS}},
+	// The fake master listens on loopback, and the co-located slave is not
+	// connected to it.
+	{"colocated-node.conf", "", []ConfigIssue{COLOCATEDNODE}, []ConfigIssue{HASINVALIDSENTINELS, SLAVECOUNTMISMATCH, LOCALADDRESS}},
+}
+
+func runFixture(t *testing.T, f *fleet, fixture, policy string) *AuditResult {
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	opts.Resolver = testResolver
+	if policy != "" {
+		file := filepath.Join(t.TempDir(), "policy.json")
+		if err := ioutil.WriteFile(file, []byte(policy), 0644); err != nil {
+			t.Fatal(err)
+		}
+		var err error
+		opts.Policy, err = LoadPolicy(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	res, err := NewAuditor(f.render(t, fixture), opts).Run()
+	if err != nil {
+		t.Fatalf("%s: %s", fixture, err)
+	}
+	return res
+}
+
+func TestGoodConfig(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	for _, fixture := range []string{"good.conf", "sentinel-pass.conf", "acl-user.conf",
+		"master-hostname.conf", "sentinel-master-hostname.conf"} {
+		res := runFixture(t, f, fixture, "")
+		if issues := res.Issues(); len(issues) > 0 {
+			t.Errorf("%s: got issues %v, want none", fixture, res.PodsByIssue)
+		}
+		if res.ExitStatus != 0 {
+			t.Errorf("%s: exit status %d, want 0", fixture, res.ExitStatus)
+		}
+	}
+}
+
+func TestSentinelAuth(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	opts.SentinelAuth = Credentials{Pass: "sentinelsecret"}
+	res, err := NewAuditor(f.render(t, "sentinel-auth-failed.conf"), opts).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := res.Issues(); len(issues) > 0 {
+		t.Errorf("got issues %v with the sentinel password given, want none", issues)
+	}
+
+	opts.SentinelAuth = Credentials{Pass: "wrong"}
+	res, err = NewAuditor(f.render(t, "sentinel-auth-failed.conf"), opts).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	protected := strings.Replace(f.ProtectedSentinel, " ", ":", 1)
+	if _, refused := res.AuthFailedSentinels[protected]; !refused || len(res.UnreachableSentinels) > 0 {
+		t.Errorf("got auth failed %v and unreachable %v, want only %s refusing the password",
+			res.AuthFailedSentinels, res.UnreachableSentinels, protected)
+	}
+
+	// A user given without a password is paired with the config's
+	// sentinel-pass, or refused when the config has none.
+	opts.SentinelAuth = Credentials{User: "default"}
+	res, err = NewAuditor(f.render(t, "sentinel-pass.conf"), opts).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := res.Issues(); len(issues) > 0 {
+		t.Errorf("got issues %v with the user and the config's sentinel-pass, want none", issues)
+	}
+	authed := false
+	commands := f.server(f.ProtectedSentinel).Commands()
+	for _, cmd := range commands {
+		if strings.Join(cmd, " ") == "AUTH default sentinelsecret" {
+			authed = true
+		}
+	}
+	if !authed {
+		t.Errorf("sentinel user was not sent: got commands %q", commands)
+	}
+	if _, err = NewAuditor(f.render(t, "sentinel-auth-failed.conf"), opts).Run(); err == nil {
+		t.Errorf("sentinel user without any password: got no error")
+	}
+}
+
+func TestACLPermissions(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	res := runFixture(t, f, "acl-permissions.conf", "")
+	master := res.Config.ManagedPodConfigs["mymaster"].Master
+	if master.ACLUser != "limited" {
+		t.Errorf("ACL user %q, want limited", master.ACLUser)
+	}
+	missing := strings.Join(master.MissingPermissions, " ")
+	for _, want := range []string{"slaveof", "config|rewrite", "subscribe", "&__sentinel__:hello"} {
+		if !strings.Contains(missing, want) {
+			t.Errorf("missing permissions %q, want %s among them", missing, want)
+		}
+	}
+	if strings.Contains(missing, "ping") || strings.Contains(missing, "info") {
+		t.Errorf("missing permissions %q include ones the user has", missing)
+	}
+}
+
+func TestHostnames(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	res := runFixture(t, f, "hostname-resolution.conf", "")
+	problems := res.Config.ManagedPodConfigs["mymaster"].HostnameProblems
+	if len(problems) != 2 || !strings.Contains(problems[0], "nxdomain.test does not resolve") ||
+		!strings.Contains(problems[1], "roundrobin.test resolves to 2 addresses") {
+		t.Errorf("got hostname problems %q, want nxdomain.test unresolved and roundrobin.test ambiguous", problems)
+	}
+
+	// Without resolve-hostnames Sentinel refuses hostnames, which needs no
+	// lookup to tell.
+	source := f.render(t, "duplicate-sentinel.conf").(BytesSource)
+	source.Content = bytes.Replace(source.Content, []byte("resolve-hostnames yes"), []byte("resolve-hostnames no"), 1)
+	opts := DefaultOptions()
+	opts.Offline = true
+	res, err := NewAuditor(source, opts).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	problems = res.Config.ManagedPodConfigs["mymaster"].HostnameProblems
+	if len(problems) != 1 || !strings.Contains(problems[0], "resolve-hostnames is off") {
+		t.Errorf("got hostname problems %q offline, want only resolve-hostnames being off", problems)
+	}
+}
+
+func TestBadConfigs(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	for _, tt := range fixtureTests {
+		res := runFixture(t, f, tt.fixture, tt.policy)
+		want := append(append([]ConfigIssue(nil), tt.want...), tt.also...)
+		sort.Sort(byIssue(want))
+		if got := res.Issues(); fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s: got %v, want %v", tt.fixture, issueNames(got), issueNames(want))
+		}
+		if res.ExitStatus == 0 {
+			t.Errorf("%s: exit status 0, want non-zero", tt.fixture)
+		}
+	}
+}
+
+// TestSilentSentinel checks a sentinel which accepts the connection but
+// never replies is given up on after the timeout rather than hanging the
+// audit.
+func TestSilentSentinel(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	opts := DefaultOptions()
+	opts.Timeout = 200 * time.Millisecond
+	opts.Resolver = testResolver
+	done := make(chan *AuditResult)
+	go func() {
+		res, err := NewAuditor(f.render(t, "silent-sentinel.conf"), opts).Run()
+		if err != nil {
+			t.Error(err)
+		}
+		done <- res
+	}()
+	select {
+	case res := <-done:
+		if res == nil {
+			return
+		}
+		if _, found := res.PodsByIssue[HASINVALIDSENTINELS]; !found {
+			t.Errorf("HASINVALIDSENTINELS not found, got %v", res.Issues())
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("audit of a silent sentinel did not finish")
+	}
+}
+
+// countingResolver resolves like fakeResolver, slowly, and records the most
+// lookups it saw in flight at once.
+type countingResolver struct {
+	fakeResolver
+	mu       sync.Mutex
+	inFlight int
+	max      int
+}
+
+func (r *countingResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	r.mu.Lock()
+	r.inFlight++
+	if r.inFlight > r.max {
+		r.max = r.inFlight
+	}
+	r.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	r.mu.Lock()
+	r.inFlight--
+	r.mu.Unlock()
+	return r.fakeResolver.LookupHost(ctx, host)
+}
+
+// TestParallelLookups checks that hostname lookups hold a prober slot, so
+// -parallel bounds them along with the connections.
+func TestParallelLookups(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	var conf bytes.Buffer
+	conf.WriteString("port 26379\nbind 127.0.0.1\nsentinel resolve-hostnames yes\n")
+	resolver := &countingResolver{fakeResolver: fakeResolver{}}
+	// The known sentinels' hostnames are first looked up as each pod is
+	// validated, all pods at once.
+	for i := 0; i < 8; i++ {
+		host := fmt.Sprintf("sentinel%d.test", i)
+		resolver.fakeResolver[host] = []string{"127.0.0.1"}
+		fmt.Fprintf(&conf, "sentinel monitor pod%d %s 2\n", i, f.Master)
+		fmt.Fprintf(&conf, "sentinel known-sentinel pod%d %s 26380 %040d\n", i, host, i)
+	}
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	opts.Parallelism = 2
+	opts.Resolver = resolver
+	if _, err := NewAuditor(BytesSource{Name: "parallel.conf", Content: conf.Bytes()}, opts).Run(); err != nil {
+		t.Fatal(err)
+	}
+	if resolver.max > opts.Parallelism {
+		t.Errorf("got %d lookups at once, want at most %d", resolver.max, opts.Parallelism)
+	}
+}
+
+func issueNames(issues []ConfigIssue) (names []string) {
+	for _, issue := range issues {
+		names = append(names, issue.Name())
+	}
+	return
+}
+
+func TestOffline(t *testing.T) {
+	// Every address is dead, so any check which dials would find issues.
+	f := &fleet{}
+	for _, addr := range []*string{&f.Master, &f.Slave, &f.Slave2, &f.Sentinel1, &f.Sentinel2,
+		&f.Sentinel3, &f.EmptySentinel, &f.SkewedSentinel, &f.Dead, &f.Dead2} {
+		*addr = deadAddress(t)
+	}
+	tests := []struct {
+		fixture string
+		want    []ConfigIssue
+	}{
+		{"good.conf", nil},
+		{"no-quorum.conf", nil},
+		{"master-unreachable.conf", nil},
+		{"not-enough-sentinels.conf", []ConfigIssue{NOTENOUGHSENTINELS}},
+		{"duplicate-master-ip.conf", []ConfigIssue{DUPLICATEMASTERIP}},
+		{"duplicate-slave-ip.conf", []ConfigIssue{DUPLICATESLAVEIP}},
+		{"quorum-too-high.conf", []ConfigIssue{QUORUMTOOHIGH}},
+		{"even-sentinels.conf", []ConfigIssue{EVENSENTINELS}},
+		{"local-address.conf", []ConfigIssue{LOCALADDRESS}},
+		{"colocated-node.conf", []ConfigIssue{COLOCATEDNODE}},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.Offline = true
+		res, err := NewAuditor(f.render(t, tt.fixture), opts).Run()
+		if err != nil {
+			t.Fatalf("%s: %s", tt.fixture, err)
+		}
+		if tt.want == nil && len(res.Issues()) > 0 {
+			t.Errorf("%s: got issues %v, want none", tt.fixture, res.Issues())
+		}
+		for _, issue := range tt.want {
+			if _, found := res.PodsByIssue[issue]; !found {
+				t.Errorf("%s: %s not found, got %v", tt.fixture, issue.Name(), res.Issues())
+			}
+		}
+		for _, issue := range []ConfigIssue{NOQUORUM, NOVALIDSLAVES, HASINVALIDSENTINELS, PODMISSINGONSENTINEL,
+			MASTERMISMATCH, MASTERUNREACHABLE, MASTERAUTHFAILED, MASTERISSLAVE, SLAVECOUNTMISMATCH} {
+			if _, found := res.PodsByIssue[issue]; found {
+				t.Errorf("%s: %s found offline", tt.fixture, issue.Name())
+			}
+		}
+		if len(res.Skipped) == 0 || len(res.UnreachableSentinels) > 0 {
+			t.Errorf("%s: got skipped %v and unreachable %v, want checks skipped and nothing probed",
+				tt.fixture, res.Skipped, res.UnreachableSentinels)
+		}
+	}
+}
+
+func TestZeroOptions(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	// Options left zero take the defaults, both offline and online.
+	for _, offline := range []bool{true, false} {
+		opts := Options{Reports: []string{"all"}, Offline: offline, Resolver: testResolver}
+		res, err := NewAuditor(f.render(t, "master-hostname.conf"), opts).Run()
+		if err != nil {
+			t.Fatalf("offline %t: %s", offline, err)
+		}
+		if issues := res.Issues(); len(issues) > 0 {
+			t.Errorf("offline %t: got issues %v, want none", offline, res.PodsByIssue)
+		}
+		if !reflect.DeepEqual(res.Policy, DefaultPolicy()) {
+			t.Errorf("offline %t: got policy %+v, want the default", offline, res.Policy)
+		}
+	}
+}
+
+func TestTLS(t *testing.T) {
+	f := newTLSFleet(t)
+	defer f.Close()
+	res := runFixture(t, f, "good-tls.conf", "")
+	if issues := res.Issues(); len(issues) > 0 {
+		t.Errorf("good-tls.conf: got issues %v, want none", res.PodsByIssue)
+	}
+
+	res = runFixture(t, f, "good.conf", "")
+	if _, found := res.PodsByIssue[MASTERUNREACHABLE]; !found {
+		t.Errorf("good.conf without TLS: got issues %v, want MASTERUNREACHABLE", res.Issues())
+	}
+
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	opts.TLS = &client.TLSOptions{CACertFile: f.CACertFile}
+	res, err := NewAuditor(f.render(t, "good.conf"), opts).Run()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if issues := res.Issues(); len(issues) > 0 {
+		t.Errorf("good.conf with TLS options: got issues %v, want none", res.PodsByIssue)
+	}
+}
+
+func TestAuditConfigs(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	dir := t.TempDir()
+	for _, fixture := range []string{"good.conf", "conflicting-master.conf"} {
+		source := f.render(t, fixture).(BytesSource)
+		err := ioutil.WriteFile(filepath.Join(dir, fixture), source.Content, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	missing := filepath.Join(dir, "missing.cfg")
+	paths, err := ExpandConfigPaths([]string{dir, filepath.Join(dir, "*.conf"), missing})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(dir, "conflicting-master.conf"), filepath.Join(dir, "good.conf"), missing}
+	if fmt.Sprint(paths) != fmt.Sprint(want) {
+		t.Fatalf("ExpandConfigPaths: got %v, want %v", paths, want)
+	}
+
+	var sources []Source
+	for _, path := range paths {
+		sources = append(sources, FileSource(path))
+	}
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	fr := AuditConfigs(sources, opts)
+	if len(fr.Results) != 2 || len(fr.Failures) != 1 {
+		t.Fatalf("got %d results and failures %v, want 2 results and %s failed", len(fr.Results), fr.Failures, missing)
+	}
+	if len(fr.Conflicts) != 1 || fr.Conflicts[0].Pod != "mymaster" || len(fr.Conflicts[0].Masters) != 2 {
+		t.Errorf("got conflicts %v, want mymaster with two masters", fr.Conflicts)
+	}
+	for _, res := range fr.Results {
+		if _, found := res.PodsByIssue[CONFLICTINGMASTER]; !found {
+			t.Errorf("%s: CONFLICTINGMASTER not found, got %v", res.Path, res.Issues())
+		}
+	}
+	if fr.ExitStatus&ExitAuditFailure == 0 || fr.ExitStatus&ExitMaster == 0 {
+		t.Errorf("exit status %d, want the audit failure and master bits set", fr.ExitStatus)
+	}
+}
+
+// TestAuditConfigsHostname checks a master named by hostname in one config
+// and by IP in another is not taken for a conflict.
+func TestAuditConfigsHostname(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	opts.Resolver = testResolver
+	sources := []Source{f.render(t, "good.conf"), f.render(t, "master-hostname.conf")}
+	fr := AuditConfigs(sources, opts)
+	if len(fr.Results) != 2 {
+		t.Fatalf("got %d results and failures %v, want 2 results", len(fr.Results), fr.Failures)
+	}
+	if len(fr.Conflicts) != 0 {
+		t.Errorf("got conflicts %v, want none", fr.Conflicts)
+	}
+}
+
+// crossConfigIssues are only found by comparing configs, in TestAuditConfigs.
+var crossConfigIssues = []ConfigIssue{CONFLICTINGMASTER}
+
+// TestEveryIssueHasFixture keeps the fixtures in step with the issues.
+func TestEveryIssueHasFixture(t *testing.T) {
+	covered := make(map[ConfigIssue]bool)
+	for _, issue := range crossConfigIssues {
+		covered[issue] = true
+	}
+	for _, tt := range fixtureTests {
+		for _, issue := range tt.want {
+			covered[issue] = true
+		}
+	}
+	for _, issue := range allIssues {
+		if !covered[issue] {
+			t.Errorf("no fixture covers %s", issue.Name())
+		}
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	tests := []struct {
+		issues []ConfigIssue
+		want   int
+	}{
+		{nil, 0},
+		{[]ConfigIssue{NOQUORUM}, 2},
+		{[]ConfigIssue{NOQUORUM, QUORUMMISMATCH}, 2},
+		{[]ConfigIssue{NOTENOUGHSENTINELS, DUPLICATESLAVEIP}, 33},
+		{[]ConfigIssue{MASTERAUTHFAILED, AUTHMISMATCH}, 16},
+		{[]ConfigIssue{DOWNAFTERTOOLOW, NOTENOUGHSENTINELS}, 65},
+		{[]ConfigIssue{NOAUTHPASS, DUPLICATEMASTERIP, MASTERUNREACHABLE}, 56},
+	}
+	for _, tt := range tests {
+		if got := ExitStatus(tt.issues); got != tt.want {
+			t.Errorf("ExitStatus(%v) = %d, want %d", tt.issues, got, tt.want)
+		}
+	}
+	all := 0
+	for _, issue := range allIssues {
+		class := ExitStatus([]ConfigIssue{issue})
+		if class == 0 {
+			t.Errorf("%s has no exit status class", issue.Name())
+		}
+		all |= class
+	}
+	if all != ExitAuditFailure-1 {
+		t.Errorf("the issues set exit status bits %b, want every bit below %d", all, ExitAuditFailure)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	numbered := func(n int) (lines []string) {
+		for i := 1; i <= n; i++ {
+			lines = append(lines, fmt.Sprintf("l%d", i))
+		}
+		return
+	}
+	tests := []struct {
+		name    string
+		lines   int
+		removed []int
+		want    string
+	}{
+		{"none", 10, nil, ""},
+		{"first line", 10, []int{0}, `@@ -1,4 +1,3 @@
+-l1
+ l2
+ l3
+ l4
+`},
+		{"last line", 10, []int{9}, `@@ -7,4 +7,3 @@
+ l7
+ l8
+ l9
+-l10
+`},
+		{"close together", 10, []int{2, 7}, `@@ -1,10 +1,8 @@
+ l1
+ l2
+-l3
+ l4
+ l5
+ l6
+ l7
+-l8
+ l9
+ l10
+`},
+		{"far apart", 20, []int{1, 15}, `@@ -1,5 +1,4 @@
+ l1
+-l2
+ l3
+ l4
+ l5
+@@ -13,7 +12,6 @@
+ l13
+ l14
+ l15
+-l16
+ l17
+ l18
+ l19
+`},
+		{"adjacent", 6, []int{2, 3}, `@@ -1,6 +1,4 @@
+ l1
+ l2
+-l3
+-l4
+ l5
+ l6
+`},
+	}
+	for _, tt := range tests {
+		var fixes []ConfigFix
+		for _, line := range tt.removed {
+			fixes = append(fixes, ConfigFix{Line: line})
+		}
+		want := tt.want
+		if want != "" {
+			want = "--- sentinel.conf\n+++ sentinel.conf\n" + want
+		}
+		if got := UnifiedDiff("sentinel.conf", numbered(tt.lines), fixes); got != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, want)
+		}
+	}
+}
+
+func TestFixConfigFile(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	dir := t.TempDir()
+	content := f.render(t, "fixable.conf").(BytesSource).Content
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	// Only the repeated known-slave, the unreachable sentinel and every line
+	// of the duplicate pod which can not authenticate are dropped; the
+	// sentinel which refuses the credentials is kept.
+	want := strings.Join(append(append([]string(nil), lines[:5]...), lines[6], lines[8]), "\n")
+
+	for _, trailingNewline := range []bool{true, false} {
+		path := filepath.Join(dir, fmt.Sprintf("sentinel-%t.conf", trailingNewline))
+		original := string(content)
+		if !trailingNewline {
+			original = strings.TrimSuffix(original, "\n")
+		}
+		if err := ioutil.WriteFile(path, []byte(original), 0640); err != nil {
+			t.Fatal(err)
+		}
+		opts := DefaultOptions()
+		opts.Timeout = time.Second
+		res, err := NewAuditor(FileSource(path), opts).Run()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		conf, err := sentinelconf.ParseFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var reasons []string
+		for _, fix := range res.PlanConfigFixes(conf) {
+			reasons = append(reasons, fmt.Sprintf("%d %s", fix.Line+1, fix.Reason))
+		}
+		dead := strings.Replace(f.Dead, " ", ":", 1)
+		slave := strings.Replace(f.Slave, " ", ":", 1)
+		wantReasons := []string{
+			"6 slave " + slave + " is already known for mymaster",
+			"8 sentinel " + dead + " is unreachable",
+			"10 pod other can not authenticate to its duplicated master",
+			"11 pod other can not authenticate to its duplicated master",
+			"12 pod other can not authenticate to its duplicated master",
+		}
+		if strings.Join(reasons, "\n") != strings.Join(wantReasons, "\n") {
+			t.Errorf("got fixes\n%s\nwant\n%s", strings.Join(reasons, "\n"), strings.Join(wantReasons, "\n"))
+		}
+
+		var out bytes.Buffer
+		if err := res.FixConfigFile(&out, path, true, nil); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := ioutil.ReadFile(path); string(got) != original {
+			t.Errorf("dry run changed %s", path)
+		}
+		declined := func(string) bool { return false }
+		if err := res.FixConfigFile(&out, path, false, declined); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := ioutil.ReadFile(path); string(got) != original {
+			t.Errorf("declined fix changed %s", path)
+		}
+
+		confirmed := func(string) bool { return true }
+		if err := res.FixConfigFile(&out, path, false, confirmed); err != nil {
+			t.Fatal(err)
+		}
+		fixed, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wantFixed := want
+		if trailingNewline {
+			wantFixed += "\n"
+		}
+		if string(fixed) != wantFixed {
+			t.Errorf("fixed %s:\n%q\nwant\n%q", path, fixed, wantFixed)
+		}
+		if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0640 {
+			t.Errorf("fixed %s: mode %v, %v, want 0640", path, info.Mode(), err)
+		}
+		backups, _ := filepath.Glob(path + ".*")
+		if len(backups) != 1 {
+			t.Fatalf("got backups %v, want one", backups)
+		}
+		if backup, _ := ioutil.ReadFile(backups[0]); string(backup) != original {
+			t.Errorf("backup %s does not hold the original", backups[0])
+		}
+	}
+	if leftover, _ := filepath.Glob(filepath.Join(dir, ".*")); len(leftover) > 0 {
+		t.Errorf("temporary files left behind: %v", leftover)
+	}
+}
+
+func TestFailoverTimeoutReason(t *testing.T) {
+	pp := DefaultPolicy().PodPolicy
+	tests := []struct {
+		usedMemory int
+		want       string
+	}{
+		// Offline, or with the master unchecked, only the minimum applies.
+		{0, "failover-timeout 10000 is below the minimum of 60000"},
+		{10 * 1024 * 1024, "failover-timeout 10000 is below the minimum of 60000"},
+		{5 * 1024 * 1024 * 1024, "failover-timeout 10000 is below 102400, the time a full resync of 5368709120 bytes may take"},
+	}
+	for _, tt := range tests {
+		pc := SentinelPodConfig{DownAfterMilliseconds: 30000, FailoverTimeout: 10000, ParallelSyncs: 1}
+		pc.Master.UsedMemory = tt.usedMemory
+		issues, reasons := pc.timingIssues(pp)
+		if fmt.Sprint(issues) != fmt.Sprint([]ConfigIssue{FAILOVERTIMEOUTTOOSHORT}) || fmt.Sprint(reasons) != fmt.Sprint([]string{tt.want}) {
+			t.Errorf("used memory %d: got %v %q, want FAILOVERTIMEOUTTOOSHORT %q", tt.usedMemory, issueNames(issues), reasons, tt.want)
+		}
+	}
+}
+
+func TestSameHostMasters(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	// The masters of mymaster and other share a host but not a port, so
+	// other, which can not authenticate, is not a duplicate to be removed.
+	// alias names mymaster's master by hostname, and is.
+	res := runFixture(t, f, "same-host-masters.conf", "")
+	if pods := res.PodsByIssue[DUPLICATEMASTERIP]; fmt.Sprint(pods) != "[alias mymaster]" {
+		t.Errorf("got DUPLICATEMASTERIP for %v, want [alias mymaster]", pods)
+	}
+	if pods := res.PodsByIssue[MASTERAUTHFAILED]; fmt.Sprint(pods) != "[alias other]" {
+		t.Errorf("got MASTERAUTHFAILED for %v, want [alias other]", pods)
+	}
+	conf, err := sentinelconf.Parse("sentinel.conf", bytes.NewReader(f.render(t, "same-host-masters.conf").(BytesSource).Content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var reasons []string
+	for _, fix := range res.PlanConfigFixes(conf) {
+		reasons = append(reasons, fmt.Sprintf("%d %s", fix.Line+1, fix.Reason))
+	}
+	wantReasons := []string{
+		"11 pod alias can not authenticate to its duplicated master",
+		"12 pod alias can not authenticate to its duplicated master",
+	}
+	if strings.Join(reasons, "\n") != strings.Join(wantReasons, "\n") {
+		t.Errorf("got fixes\n%s\nwant\n%s", strings.Join(reasons, "\n"), strings.Join(wantReasons, "\n"))
+	}
+	var removed []string
+	for _, action := range res.PlanRemediation() {
+		if action.Command == "REMOVE" {
+			removed = append(removed, action.Pod)
+		}
+	}
+	if fmt.Sprint(removed) != "[alias]" {
+		t.Errorf("got REMOVE for %v, want [alias]", removed)
+	}
+}
+
+func TestRemediate(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	// The config is that of Sentinel3, which is sent the local commands.
+	local, empty := f.server(f.Sentinel3), f.server(f.EmptySentinel)
+	local.Reply("SENTINEL RESET", 1)
+	empty.Reply("SENTINEL MONITOR", redistest.Status("OK"))
+	empty.Reply("SENTINEL SET", redistest.Status("OK"))
+	res := runFixture(t, f, "remediable.conf", "")
+
+	var actions []string
+	for _, action := range res.PlanRemediation() {
+		actions = append(actions, fmt.Sprintf("%s on %s", action.Command, action.Pod))
+	}
+	want := []string{"RESET on cache[1]*", "MONITOR on mymaster"}
+	if fmt.Sprint(actions) != fmt.Sprint(want) {
+		t.Fatalf("got plan %q, want %q", actions, want)
+	}
+
+	before, emptyBefore := len(local.Commands()), len(empty.Commands())
+	var out bytes.Buffer
+	err := res.Remediate(&out, true, func(string) bool { return true })
+	if err != nil {
+		t.Fatalf("%s\n%s", err, out.String())
+	}
+	var sent []string
+	for _, cmd := range local.Commands()[before:] {
+		sent = append(sent, strings.Join(cmd, " "))
+	}
+	for _, cmd := range empty.Commands()[emptyBefore:] {
+		sent = append(sent, strings.Join(cmd, " "))
+	}
+	master := strings.Fields(f.Master)
+	wantSent := []string{
+		`SENTINEL RESET cache\[1\]\*`,
+		"SENTINEL MONITOR mymaster " + master[0] + " " + master[1] + " 2",
+		"SENTINEL SET mymaster down-after-milliseconds 10000",
+		"SENTINEL SET mymaster failover-timeout 120000",
+		"SENTINEL SET mymaster parallel-syncs 1",
+		"SENTINEL SET mymaster rename-command CONFIG MYCONFIG",
+		"SENTINEL SET mymaster notification-script /usr/local/bin/notify.sh",
+		"SENTINEL SET mymaster client-reconfig-script /usr/local/bin/reconfig.sh",
+		"SENTINEL SET mymaster AUTH-PASS secret",
+	}
+	if strings.Join(sent, "\n") != strings.Join(wantSent, "\n") {
+		t.Errorf("sent\n%s\nwant\n%s", strings.Join(sent, "\n"), strings.Join(wantSent, "\n"))
+	}
+
+	// A sentinel refusing a setting, as it does scripts under
+	// deny-scripts-reconfig, fails the action.
+	empty.Reply("SENTINEL SET", redistest.Error("ERR Reconfiguration of scripts path is denied"))
+	out.Reset()
+	if err := res.Remediate(&out, true, func(string) bool { return true }); err == nil {
+		t.Errorf("refused SENTINEL SET did not fail:\n%s", out.String())
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	stop := make(chan struct{})
+	defer close(stop)
+	// Two exporters can be served side by side, each on its own mux.
+	for _, fixture := range []string{"good.conf", "no-slaves.conf"} {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", NewAuditor(f.render(t, fixture), opts).MetricsHandler(time.Hour, stop))
+		srv := httptest.NewServer(mux)
+		defer srv.Close()
+		resp, err := http.Get(srv.URL + "/metrics")
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := `sentinel_audit_pod_issue{pod="mymaster",issue="NOSLAVES"} 0`
+		if fixture == "no-slaves.conf" {
+			want = `sentinel_audit_pod_issue{pod="mymaster",issue="NOSLAVES"} 1`
+		}
+		for _, line := range []string{want, "sentinel_audit_success 1"} {
+			if !strings.Contains(string(body), line+"\n") {
+				t.Errorf("%s: metrics lack %q:\n%s", fixture, line, body)
+			}
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	f := newFleet(t)
+	defer f.Close()
+	render := func(fixture string) []byte { return f.render(t, fixture).(BytesSource).Content }
+	path := filepath.Join(t.TempDir(), "sentinel.conf")
+	if err := ioutil.WriteFile(path, render("good.conf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts := DefaultOptions()
+	opts.Timeout = time.Second
+	opts.Resolver = testResolver
+	deltas := make(chan *Delta, 10)
+	stop := make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- NewAuditor(FileSource(path), opts).Watch(100*time.Millisecond, stop, func(d *Delta) { deltas <- d })
+	}()
+	next := func() *Delta {
+		select {
+		case d := <-deltas:
+			return d
+		case <-time.After(5 * time.Second):
+			t.Fatal("no audit after the config changed")
+		}
+		return nil
+	}
+
+	if d := next(); len(d.PodsAdded) != 1 || d.PodsAdded[0] != "mymaster" || len(d.NewIssues) > 0 {
+		t.Errorf("first delta %+v, want mymaster added without issues", d)
+	}
+	// Edited in place, as Redis before 7 rewrites the config.
+	if err := ioutil.WriteFile(path, render("no-slaves.conf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if d := next(); !containsIssue(d.NewIssues["mymaster"], NOSLAVES) || len(d.ResolvedIssues) > 0 {
+		t.Errorf("delta %+v, want NOSLAVES new for mymaster", d)
+	}
+	// Replaced by rename, as Redis 7 rewrites the config.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, render("good.conf"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+	if d := next(); !containsIssue(d.ResolvedIssues["mymaster"], NOSLAVES) || len(d.NewIssues) > 0 {
+		t.Errorf("delta %+v, want NOSLAVES resolved for mymaster", d)
+	}
+	close(stop)
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+func containsIssue(issues []ConfigIssue, issue ConfigIssue) bool {
+	for _, i := range issues {
+		if i == issue {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPollWatcher(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sentinel.conf")
+	if err := ioutil.WriteFile(path, []byte("port 26379\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := newPollWatcher(path, 10*time.Millisecond)
+	defer w.Close()
+	if err := ioutil.WriteFile(path, []byte("port 26380\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.Changes():
+	case <-time.After(5 * time.Second):
+		t.Error("change not noticed")
+	}
+}
@@ -0,0 +1,187 @@
+// Package audit checks a Redis Sentinel config, and the sentinels and nodes
+// it names, for conditions which pass Sentinel's own syntax check but break
+// or weaken failover.
+//
+// An Auditor holds a config source and the options for auditing it; each
+// call to Run loads the config afresh and returns an AuditResult. Runs share
+// no state, so several configs may be audited in one process, concurrently
+// if need be.
+package audit
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/therealbill/libredis/client"
+)
+
+// Options controls how an Auditor runs.
+type Options struct {
+	// Reports are the reports to run: all, baseconfig, known-sentinels or
+	// constellation. Only the pods checked by the all report get issues.
+	Reports []string
+	// Policy holds the thresholds and expectations every check reads. A
+	// zero Policy is the DefaultPolicy.
+	Policy Policy
+	// Parallelism is how many sentinels and nodes are probed at once.
+	Parallelism int
+	// Timeout is how long to wait when connecting to a sentinel or node, and
+	// for each command it is sent. Zero is the DefaultOptions timeout.
+	Timeout time.Duration
+	// Output receives the human readable reports. Nil discards them.
+	Output io.Writer
+	// SentinelAuth is used for every connection to a sentinel. When it has
+	// no Pass the config's sentinel-user and sentinel-pass are used; a User
+	// without a Pass is paired with the config's sentinel-pass, and Run
+	// fails if the config has none.
+	SentinelAuth Credentials
+	// TLS, when set, makes every connection over TLS. Files it leaves
+	// empty are taken from the config's tls-* settings. When it is nil the
+	// config's tls-replication decides.
+	TLS *client.TLSOptions
+	// Offline makes only the checks which need no network access. Nothing
+	// is dialed, and the checks which would have been are listed as skipped.
+	Offline bool
+	// Resolver looks up the hostnames the config names. Nil uses the
+	// system resolver.
+	Resolver Resolver
+}
+
+// networkChecks are the checks an offline audit skips.
+var networkChecks = []string{
+	"known sentinel reachability",
+	"constellation consistency",
+	"master validation",
+	"slave validation",
+	"reachable quorum",
+	"hostname resolution",
+}
+
+// DefaultOptions runs every report against the default policy.
+func DefaultOptions() Options {
+	return Options{
+		Reports:     []string{"all"},
+		Policy:      DefaultPolicy(),
+		Parallelism: 16,
+		Timeout:     2 * time.Second,
+	}
+}
+
+// withDefaults returns opts with a zero Policy and Timeout replaced by those
+// of DefaultOptions.
+func (opts Options) withDefaults() Options {
+	defaults := DefaultOptions()
+	if reflect.DeepEqual(opts.Policy, Policy{}) {
+		opts.Policy = defaults.Policy
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaults.Timeout
+	}
+	return opts
+}
+
+// Auditor audits the sentinel config from Source.
+type Auditor struct {
+	Source  Source
+	Options Options
+}
+
+// NewAuditor returns an Auditor for the config from source.
+func NewAuditor(source Source, opts Options) *Auditor {
+	return &Auditor{Source: source, Options: opts}
+}
+
+// run is the state of a single audit: the loaded config and everything the
+// reports find in it.
+type run struct {
+	opts                 Options
+	out                  io.Writer
+	lsconf               LocalSentinelConfig
+	prober               *Prober
+	addrs                *addressBook
+	local                localAddresses
+	podsWithIssues       map[ConfigIssue][]SentinelPodConfig
+	masterIPtoPodMapping map[string]SentinelPodConfig
+}
+
+// Run loads the config and runs the reports against it. The error is only
+// set when the config could not be loaded; the issues found are in the
+// result.
+func (a *Auditor) Run() (*AuditResult, error) {
+	opts := a.Options.withDefaults()
+	r := &run{
+		opts:           opts,
+		out:            opts.Output,
+		prober:         NewProber(opts.Parallelism, opts.Timeout),
+		addrs:          newAddressBook(opts.Resolver, opts.Timeout, opts.Offline),
+		podsWithIssues: make(map[ConfigIssue][]SentinelPodConfig),
+	}
+	if r.out == nil {
+		r.out = ioutil.Discard
+	}
+	var err error
+	r.lsconf, err = LoadConfig(a.Source)
+	if err != nil {
+		return nil, err
+	}
+	r.local, err = newLocalAddresses(r.lsconf.Host)
+	if err != nil {
+		fmt.Fprintf(r.out, "Unable to list the local interface addresses: %s\n", err)
+	}
+	r.prober.Auth = opts.SentinelAuth
+	switch {
+	case r.prober.Auth.Pass != "":
+	case r.prober.Auth.User != "":
+		if r.lsconf.SentinelPass == "" {
+			return nil, fmt.Errorf("sentinel user %s has no password: give one, or set sentinel-pass in %s",
+				r.prober.Auth.User, a.Source.Path())
+		}
+		r.prober.Auth.Pass = r.lsconf.SentinelPass
+	default:
+		r.prober.Auth = Credentials{User: r.lsconf.SentinelUser, Pass: r.lsconf.SentinelPass}
+	}
+	r.prober.TLS = r.lsconf.tlsOptions()
+	if opts.TLS != nil {
+		tlsOpts := *opts.TLS
+		if conf := r.prober.TLS; conf != nil {
+			if tlsOpts.CACertFile == "" {
+				tlsOpts.CACertFile = conf.CACertFile
+			}
+			if tlsOpts.CertFile == "" && tlsOpts.KeyFile == "" {
+				tlsOpts.CertFile, tlsOpts.KeyFile = conf.CertFile, conf.KeyFile
+			}
+		}
+		r.prober.TLS = &tlsOpts
+	}
+	runAt := time.Now()
+	fmt.Fprintf(r.out, "Configuration Audit Run for Sentinel '%s' at %s\n", r.lsconf.Name, runAt)
+	if r.opts.Offline {
+		fmt.Fprintf(r.out, "Offline: skipping %s\n", strings.Join(networkChecks, ", "))
+	}
+	fmt.Fprintln(r.out)
+
+	for _, rep := range r.opts.Reports {
+		switch rep {
+		case "baseconfig":
+			r.baseConfigReport()
+
+		case "known-sentinels":
+			r.knownSentinelsReport()
+
+		case "constellation":
+			r.constellationReport()
+
+		case "all", "":
+			r.runAllReports()
+
+		default:
+			fmt.Fprintf(r.out, "Unknown report '%s'\n", rep)
+
+		}
+	}
+	return r.result(a.Source.Path(), runAt), nil
+}
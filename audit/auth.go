@@ -0,0 +1,59 @@
+package audit
+
+import (
+	"strings"
+
+	"github.com/therealbill/libredis/client"
+)
+
+// Credentials authenticate a connection, as the ACL user User when it is set
+// and as the default user otherwise.
+type Credentials struct {
+	User string
+	Pass string `json:"-"`
+}
+
+// isAuthError reports whether err is Redis refusing our credentials, rather
+// than a failure to connect.
+func isAuthError(err error) bool {
+	msg := err.Error()
+	return strings.HasPrefix(msg, "NOAUTH") || strings.HasPrefix(msg, "WRONGPASS") ||
+		strings.Contains(msg, "invalid password") || isNoPasswordError(err)
+}
+
+// isNoPasswordError reports whether err is a server refusing AUTH because it
+// has no password set, as Redis before 6 and from 6 on word it.
+func isNoPasswordError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "no password is set") || strings.Contains(msg, "without any password configured")
+}
+
+// credentials are those the pod's sentinels use with its master and slaves:
+// its auth-user, if any, and auth-pass.
+func (pc *SentinelPodConfig) credentials() Credentials {
+	return Credentials{User: pc.AuthUser, Pass: pc.AuthToken}
+}
+
+// dial connects to addr with creds, over TLS when the prober has TLS
+// options.
+func (p *Prober) dial(addr string, creds Credentials) (*client.Redis, error) {
+	return client.DialWithConfig(&client.DialConfig{
+		Address:  addr,
+		Username: creds.User,
+		Password: creds.Pass,
+		Timeout:  p.Timeout,
+		TLS:      p.TLS,
+	})
+}
+
+// dialSentinel connects to the sentinel at addr with the prober's sentinel
+// credentials. A sentinel which has no password refuses AUTH, so the dial is
+// retried without credentials; one set of credentials then serves a
+// constellation in which only some of the sentinels require them.
+func (p *Prober) dialSentinel(addr string) (*client.Redis, error) {
+	conn, err := p.dial(addr, p.Auth)
+	if err != nil && p.Auth.Pass != "" && isNoPasswordError(err) {
+		return p.dial(addr, Credentials{})
+	}
+	return conn, err
+}
@@ -0,0 +1,390 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/therealbill/audit-sentinel-config/sentinelconf"
+	"github.com/therealbill/libredis/client"
+	"github.com/therealbill/libredis/info"
+)
+
+type NodeInfo struct {
+	Name      string
+	Info      info.RedisInfoAll
+	AuthToken string
+	TLS       *client.TLSOptions
+}
+
+func (n *NodeInfo) MaxMemory() (int64, error) {
+	var config client.DialConfig
+	config.Address = n.Name
+	config.Password = n.AuthToken
+	config.TLS = n.TLS
+	var maxmem int64
+	conn, err := client.DialWithConfig(&config)
+	if err != nil {
+		return maxmem, err
+	}
+	defer conn.ClosePool()
+	res, err := conn.ConfigGet("maxmemory")
+	if err != nil {
+		return maxmem, err
+	}
+	maxmem, err = strconv.ParseInt(res["maxmemory"], 10, 64)
+	return maxmem, nil
+
+}
+
+type SentinelPodConfig struct {
+	IP                    string
+	Port                  int
+	Quorum                int
+	Name                  string
+	AuthToken             string `json:"-"`
+	AuthUser              string
+	DownAfterMilliseconds int
+	FailoverTimeout       int
+	ParallelSyncs         int
+	NotificationScript    string
+	ReconfigScript        string
+	RenamedCommands       map[string]string
+	Sentinels             map[string]string
+	Slaves                []string
+	ConfirmedSentinels    map[string]string
+	InvalidSentinels      map[string]string
+	AuthFailedSentinels   map[string]string
+	ConfirmedSlaves       map[string]string
+	InvalidSlaves         map[string]string
+	// HostnameProblems describes each hostname the pod names which Sentinel
+	// will refuse or may resolve to the wrong node.
+	HostnameProblems []string
+	// DuplicateSentinels are pairs of known sentinels which are the same
+	// sentinel under different addresses.
+	DuplicateSentinels []string
+	// LocalAddresses describes the master and slaves addressed by loopback,
+	// which the pod's other sentinels can not reach.
+	LocalAddresses []string
+	// ColocatedNodes describes the master and slaves at the sentinel's bind
+	// or interface addresses, which fail together with the sentinel.
+	ColocatedNodes []string
+	// Validated is set once the pod's sentinels and slaves have been probed.
+	// Until then only the checks which need no network access are made.
+	Validated     bool
+	Master        MasterStatus
+	Issues        []ConfigIssue
+	Discrepancies []Discrepancy
+}
+
+// MasterStatus is what the pod's master reported when it was checked.
+type MasterStatus struct {
+	Checked         bool
+	Error           string
+	AuthFailed      bool
+	Role            string
+	ConnectedSlaves int
+	UsedMemory      int
+	// ACLUser is the user ACL WHOAMI reported for the pod's auth-user.
+	ACLUser string
+	// MissingPermissions are the commands and channels Sentinel needs which
+	// the pod's auth-user lacks on the master.
+	MissingPermissions []string
+	// ACLError is why the auth-user's permissions could not be verified.
+	ACLError string
+}
+
+type LocalSentinelConfig struct {
+	Name                string
+	Host                string
+	Port                int
+	ManagedPodConfigs   map[string]SentinelPodConfig
+	Dir                 string
+	MyID                string
+	AnnounceIP          string
+	AnnouncePort        int
+	ResolveHostnames    bool
+	AnnounceHostnames   bool
+	DenyScriptsReconfig bool
+	SentinelUser        string
+	SentinelPass        string `json:"-"`
+	// The tls-* settings. With tls-replication the sentinel makes its own
+	// connections over TLS, and so does the audit.
+	TLSPort           int
+	TLSReplication    bool
+	TLSCACertFile     string
+	TLSCertFile       string
+	TLSKeyFile        string
+	TLSClientCertFile string
+	TLSClientKeyFile  string
+	KnownSentinels    map[string]string
+	Diagnostics       []sentinelconf.Diagnostic
+}
+
+// Source supplies the sentinel config to audit.
+type Source interface {
+	// Path names the config in reports and diagnostics.
+	Path() string
+	// Parse reads and parses the config.
+	Parse() (*sentinelconf.File, error)
+}
+
+// FileSource reads the config from the named file.
+type FileSource string
+
+func (f FileSource) Path() string { return string(f) }
+
+func (f FileSource) Parse() (*sentinelconf.File, error) {
+	return sentinelconf.ParseFile(string(f))
+}
+
+// BytesSource is a config held in memory, such as one fetched from a
+// configuration store.
+type BytesSource struct {
+	Name    string
+	Content []byte
+}
+
+func (b BytesSource) Path() string { return b.Name }
+
+func (b BytesSource) Parse() (*sentinelconf.File, error) {
+	return sentinelconf.Parse(b.Name, bytes.NewReader(b.Content))
+}
+
+// LoadConfig parses the config from source into the local sentinel's view
+// of the pods it monitors. Problems with individual directives are recorded
+// as diagnostics rather than failing the load.
+func LoadConfig(source Source) (LocalSentinelConfig, error) {
+	conf, err := source.Parse()
+	if err != nil {
+		return LocalSentinelConfig{}, err
+	}
+	return newLocalSentinelConfig(conf), nil
+}
+
+func newLocalSentinelConfig(conf *sentinelconf.File) LocalSentinelConfig {
+	lsconf := LocalSentinelConfig{
+		ManagedPodConfigs: make(map[string]SentinelPodConfig),
+		KnownSentinels:    make(map[string]string),
+	}
+	// The local sentinel's address is needed to recognise it among the known
+	// sentinels, so pick it up before anything else.
+	for _, node := range conf.Nodes {
+		switch n := node.(type) {
+		case *sentinelconf.Port:
+			lsconf.Port = n.Port
+		case *sentinelconf.Bind:
+			lsconf.Host = n.Addresses[0]
+		case *sentinelconf.Option:
+			if n.Name == "tls-port" && len(n.Args) == 1 {
+				lsconf.TLSPort, _ = strconv.Atoi(n.Args[0])
+			}
+		}
+	}
+	// A TLS-only sentinel is reached on its tls-port.
+	if lsconf.Port == 0 && lsconf.TLSPort > 0 {
+		lsconf.Port = lsconf.TLSPort
+	}
+	if lsconf.Host > "" && lsconf.Port > 0 {
+		lsconf.Name = fmt.Sprintf("%s:%d", lsconf.Host, lsconf.Port)
+	}
+	for _, node := range conf.Nodes {
+		lsconf.applyDirective(conf, node)
+	}
+	sort.SliceStable(conf.Diagnostics, func(i, j int) bool {
+		return conf.Diagnostics[i].Pos.Line < conf.Diagnostics[j].Pos.Line
+	})
+	lsconf.Diagnostics = conf.Diagnostics
+	return lsconf
+}
+
+// ignoredDirectives are the general server directives a sentinel config may
+// contain which have no bearing on the audit.
+var ignoredDirectives = map[string]bool{
+	"maxclients":      true,
+	"daemonize":       true,
+	"pidfile":         true,
+	"logfile":         true,
+	"loglevel":        true,
+	"syslog-enabled":  true,
+	"syslog-ident":    true,
+	"syslog-facility": true,
+	"protected-mode":  true,
+	"supervised":      true,
+	"user":            true,
+	"aclfile":         true,
+	"acllog-max-len":  true,
+}
+
+// applyDirective records a parsed directive. Directives for a pod which has
+// not been monitored yet are reported as diagnostics on conf.
+func (lsconf *LocalSentinelConfig) applyDirective(conf *sentinelconf.File, node sentinelconf.Node) {
+	podFor := func(name string) (SentinelPodConfig, bool) {
+		pc, exists := lsconf.ManagedPodConfigs[name]
+		if !exists {
+			conf.Warnf(node.Position(), "directive for pod '%s' which is not monitored", name)
+		}
+		return pc, exists
+	}
+	switch n := node.(type) {
+	case *sentinelconf.Port, *sentinelconf.Bind:
+		// handled by newLocalSentinelConfig
+	case *sentinelconf.Dir:
+		lsconf.Dir = n.Path
+
+	case *sentinelconf.Monitor:
+		if _, exists := lsconf.ManagedPodConfigs[n.Pod]; exists {
+			conf.Warnf(n.Pos, "pod '%s' is monitored more than once, ignoring this one", n.Pod)
+			return
+		}
+		lsconf.ManagedPodConfigs[n.Pod] = SentinelPodConfig{
+			Name:                  n.Pod,
+			IP:                    n.Host,
+			Port:                  n.Port,
+			Quorum:                n.Quorum,
+			Sentinels:             make(map[string]string),
+			DownAfterMilliseconds: defaultDownAfter,
+			FailoverTimeout:       defaultFailoverTimeout,
+			ParallelSyncs:         defaultParallelSyncs,
+		}
+
+	case *sentinelconf.AuthPass:
+		if pc, ok := podFor(n.Pod); ok {
+			pc.AuthToken = n.Password
+			lsconf.ManagedPodConfigs[n.Pod] = pc
+		}
+
+	case *sentinelconf.KnownSentinel:
+		if pc, ok := podFor(n.Pod); ok {
+			sentinel_address := fmt.Sprintf("%s:%d", n.Host, n.Port)
+			pc.Sentinels[sentinel_address] = ""
+			isMe := sentinel_address == lsconf.Name
+			if !isMe {
+				lsconf.KnownSentinels[sentinel_address] = sentinel_address
+			}
+		}
+
+	case *sentinelconf.KnownSlave:
+		if pc, ok := podFor(n.Pod); ok {
+			pc.Slaves = append(pc.Slaves, fmt.Sprintf("%s:%d", n.Host, n.Port))
+			lsconf.ManagedPodConfigs[n.Pod] = pc
+		}
+
+	case *sentinelconf.AuthUser:
+		if pc, ok := podFor(n.Pod); ok {
+			pc.AuthUser = n.User
+			lsconf.ManagedPodConfigs[n.Pod] = pc
+		}
+
+	case *sentinelconf.RenameCommand:
+		if pc, ok := podFor(n.Pod); ok {
+			if pc.RenamedCommands == nil {
+				pc.RenamedCommands = make(map[string]string)
+			}
+			pc.RenamedCommands[n.Command] = n.RenamedTo
+			lsconf.ManagedPodConfigs[n.Pod] = pc
+		}
+
+	case *sentinelconf.PodInt:
+		if pc, ok := podFor(n.Pod); ok {
+			switch n.Name {
+			case "down-after-milliseconds":
+				pc.DownAfterMilliseconds = n.Value
+			case "failover-timeout":
+				pc.FailoverTimeout = n.Value
+			case "parallel-syncs":
+				pc.ParallelSyncs = n.Value
+			}
+			// We don't use the epochs
+			lsconf.ManagedPodConfigs[n.Pod] = pc
+		}
+
+	case *sentinelconf.PodOption:
+		if pc, ok := podFor(n.Pod); ok {
+			switch n.Name {
+			case "notification-script":
+				pc.NotificationScript = n.Args[0]
+			case "client-reconfig-script":
+				pc.ReconfigScript = n.Args[0]
+			}
+			lsconf.ManagedPodConfigs[n.Pod] = pc
+		}
+
+	case *sentinelconf.SentinelSetting:
+		switch n.Name {
+		case "myid":
+			lsconf.MyID = n.Value
+		case "sentinel-user":
+			lsconf.SentinelUser = n.Value
+		case "sentinel-pass":
+			lsconf.SentinelPass = n.Value
+		case "announce-ip":
+			lsconf.AnnounceIP = n.Value
+		case "announce-port":
+			lsconf.AnnouncePort, _ = strconv.Atoi(n.Value)
+		}
+
+	case *sentinelconf.SentinelFlag:
+		switch n.Name {
+		case "resolve-hostnames":
+			lsconf.ResolveHostnames = n.Enabled
+		case "announce-hostnames":
+			lsconf.AnnounceHostnames = n.Enabled
+		case "deny-scripts-reconfig":
+			lsconf.DenyScriptsReconfig = n.Enabled
+		}
+
+	case *sentinelconf.Option:
+		switch {
+		case strings.HasPrefix(n.Name, "tls-"):
+			lsconf.applyTLSDirective(n)
+		case ignoredDirectives[n.Name]:
+		case n.Name == "sentinel" && n.Args[0] == "current-epoch":
+		default:
+			conf.Warnf(n.Pos, "unhandled directive: %s %s", n.Name, strings.Join(n.Args, " "))
+		}
+	}
+}
+
+// applyTLSDirective records the tls-* settings which decide how the sentinel,
+// and so the audit, connects to the others. The rest only concern the
+// sentinel's own listener.
+func (lsconf *LocalSentinelConfig) applyTLSDirective(n *sentinelconf.Option) {
+	if len(n.Args) != 1 {
+		return
+	}
+	switch n.Name {
+	case "tls-replication":
+		lsconf.TLSReplication = strings.EqualFold(n.Args[0], "yes")
+	case "tls-ca-cert-file":
+		lsconf.TLSCACertFile = n.Args[0]
+	case "tls-cert-file":
+		lsconf.TLSCertFile = n.Args[0]
+	case "tls-key-file":
+		lsconf.TLSKeyFile = n.Args[0]
+	case "tls-client-cert-file":
+		lsconf.TLSClientCertFile = n.Args[0]
+	case "tls-client-key-file":
+		lsconf.TLSClientKeyFile = n.Args[0]
+	}
+}
+
+// tlsOptions returns the TLS settings the sentinel connects to others with,
+// or nil if it does not use TLS. Like Redis, the server certificate doubles
+// as the client certificate when no tls-client-cert-file is given.
+func (lsconf LocalSentinelConfig) tlsOptions() *client.TLSOptions {
+	if !lsconf.TLSReplication {
+		return nil
+	}
+	opts := &client.TLSOptions{
+		CACertFile: lsconf.TLSCACertFile,
+		CertFile:   lsconf.TLSClientCertFile,
+		KeyFile:    lsconf.TLSClientKeyFile,
+	}
+	if opts.CertFile == "" {
+		opts.CertFile, opts.KeyFile = lsconf.TLSCertFile, lsconf.TLSKeyFile
+	}
+	return opts
+}
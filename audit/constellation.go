@@ -0,0 +1,137 @@
+package audit
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/therealbill/libredis/client"
+)
+
+// staleInfoRefresh is how old, in milliseconds, a sentinel's last INFO reply
+// from a master may be before we consider it unable to talk to the master.
+// Sentinels normally refresh INFO every ten seconds.
+const staleInfoRefresh = 30000
+
+// remoteMasters returns the pods monitored by the sentinel at addr, keyed by
+// pod name.
+func remoteMasters(prober *Prober, addr string) (map[string]client.MasterInfo, error) {
+	conn, err := prober.dialSentinel(addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.ClosePool()
+	masters, err := conn.SentinelMasters()
+	if err != nil {
+		return nil, err
+	}
+	views := make(map[string]client.MasterInfo)
+	for _, m := range masters {
+		views[m.Name] = m
+	}
+	return views, nil
+}
+
+// canInfo reports whether a sentinel's view of a master shows it getting INFO
+// replies. SENTINEL MASTER does not expose auth-pass, so a sentinel which is
+// connected to the master but never gets INFO back is the visible symptom of
+// a missing or wrong auth-pass.
+func canInfo(view client.MasterInfo) bool {
+	if strings.Contains(view.Flags, "down") || strings.Contains(view.Flags, "disconnected") {
+		return true
+	}
+	return view.InfoRefresh > 0 && view.InfoRefresh <= staleInfoRefresh
+}
+
+// Discrepancy is a difference between a known sentinel's view of a pod and
+// the local config.
+type Discrepancy struct {
+	Sentinel string
+	Issue    ConfigIssue
+	Detail   string
+}
+
+// addDiscrepancy records a difference between the view of a pod held by
+// sentinel and the local config.
+func (r *run) addDiscrepancy(issue ConfigIssue, podname, sentinel string, format string, args ...interface{}) {
+	d := Discrepancy{Sentinel: sentinel, Issue: issue, Detail: fmt.Sprintf(format, args...)}
+	fmt.Fprintf(r.out, "  %s: %s\n", podname, d.Detail)
+	pc := r.lsconf.ManagedPodConfigs[podname]
+	pc.Discrepancies = append(pc.Discrepancies, d)
+	r.lsconf.ManagedPodConfigs[podname] = pc
+	r.recordIssue(issue, pc)
+}
+
+// constellationReport connects to every known sentinel and compares its view
+// of each pod it is listed for against the local config: the pod must be
+// monitored, with the same master address and quorum, and the sentinel must
+// be able to authenticate to the master.
+func (r *run) constellationReport() {
+	var sentinels []string
+	for s := range r.lsconf.KnownSentinels {
+		sentinels = append(sentinels, s)
+	}
+	sort.Strings(sentinels)
+	var podnames []string
+	for name := range r.lsconf.ManagedPodConfigs {
+		podnames = append(podnames, name)
+	}
+	sort.Strings(podnames)
+
+	fmt.Fprintf(r.out, "Constellation Consistency (%d sentinels):\n", len(sentinels))
+	fmt.Fprintf(r.out, "=====================================\n")
+	if r.opts.Offline {
+		fmt.Fprintln(r.out, "SKIPPED - offline")
+		fmt.Fprintln(r.out)
+		return
+	}
+	// Fetch every sentinel's view at once, then compare them in order.
+	type remoteView struct {
+		views map[string]client.MasterInfo
+		err   error
+	}
+	remote := make([]remoteView, len(sentinels))
+	var wg sync.WaitGroup
+	for i, s := range sentinels {
+		wg.Add(1)
+		go func(i int, s string) {
+			defer wg.Done()
+			if err := r.prober.Probe(s); err != nil {
+				remote[i].err = err
+				return
+			}
+			r.prober.Run(func() { remote[i].views, remote[i].err = remoteMasters(r.prober, s) })
+		}(i, s)
+	}
+	wg.Wait()
+	for i, s := range sentinels {
+		views, err := remote[i].views, remote[i].err
+		if err != nil {
+			fmt.Fprintf(r.out, "%s (SKIPPED - err: '%s')\n", s, err)
+			continue
+		}
+		fmt.Fprintf(r.out, "%s (%d pods)\n", s, len(views))
+		for _, name := range podnames {
+			pc := r.lsconf.ManagedPodConfigs[name]
+			if _, listed := pc.Sentinels[s]; !listed {
+				continue
+			}
+			view, monitored := views[name]
+			if !monitored {
+				r.addDiscrepancy(PODMISSINGONSENTINEL, name, s, "not monitored by sentinel %s", s)
+				continue
+			}
+			if r.addrs.canonicalHost(view.IP) != r.addrs.canonicalHost(pc.IP) || view.Port != pc.Port {
+				r.addDiscrepancy(MASTERMISMATCH, name, s, "sentinel %s has master %s:%d, local config has %s:%d", s, view.IP, view.Port, pc.IP, pc.Port)
+			}
+			if view.Quorum != pc.Quorum {
+				r.addDiscrepancy(QUORUMMISMATCH, name, s, "sentinel %s has quorum %d, local config has %d", s, view.Quorum, pc.Quorum)
+			}
+			if pc.AuthToken != "" && !canInfo(view) {
+				r.addDiscrepancy(AUTHMISMATCH, name, s, "sentinel %s is not getting INFO from the master, check its auth-pass", s)
+			}
+		}
+	}
+	fmt.Fprintln(r.out)
+}
@@ -0,0 +1,240 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/therealbill/audit-sentinel-config/sentinelconf"
+)
+
+// ConfigFix is a line to drop from the config file and why.
+type ConfigFix struct {
+	Line   int
+	Reason string
+}
+
+// podsToRemove returns the pods which share a master address with another
+// pod and can not authenticate to it while at least one of the others can.
+// Those are the pods FindDupeMasterIPs recommends deleting.
+func (res *AuditResult) podsToRemove() map[string]bool {
+	byAddr := make(map[string][]SentinelPodConfig)
+	for _, pod := range res.Config.ManagedPodConfigs {
+		for _, issue := range pod.Issues {
+			if issue == DUPLICATEMASTERIP {
+				addr := res.addrs.canonical(fmt.Sprintf("%s:%d", pod.IP, pod.Port))
+				byAddr[addr] = append(byAddr[addr], pod)
+			}
+		}
+	}
+	remove := make(map[string]bool)
+	for _, pods := range byAddr {
+		authenticated := false
+		for _, pod := range pods {
+			if pod.Master.Checked && pod.Master.Error == "" {
+				authenticated = true
+			}
+		}
+		if !authenticated {
+			continue
+		}
+		for _, pod := range pods {
+			if pod.Master.AuthFailed {
+				remove[pod.Name] = true
+			}
+		}
+	}
+	return remove
+}
+
+// PlanConfigFixes works out which lines of the config file to drop, based on
+// the results of the reports: known-sentinel lines for unreachable sentinels,
+// every line of an unauthenticatable duplicate pod, and repeated known-slave
+// lines.
+func (res *AuditResult) PlanConfigFixes(conf *sentinelconf.File) (fixes []ConfigFix) {
+	remove := res.podsToRemove()
+	seenSlaves := make(map[string]bool)
+	for _, node := range conf.Nodes {
+		line := node.Position().Line - 1
+		pn, ok := node.(sentinelconf.PodNode)
+		if !ok {
+			continue
+		}
+		podname := pn.PodName()
+		switch n := node.(type) {
+		case *sentinelconf.KnownSentinel:
+			addr := fmt.Sprintf("%s:%d", n.Host, n.Port)
+			if res.isUnreachable(addr) && !remove[podname] {
+				fixes = append(fixes, ConfigFix{line, fmt.Sprintf("sentinel %s is unreachable", addr)})
+				continue
+			}
+		case *sentinelconf.KnownSlave:
+			key := fmt.Sprintf("%s %s:%d", n.Pod, n.Host, n.Port)
+			if seenSlaves[key] && !remove[podname] {
+				fixes = append(fixes, ConfigFix{line, fmt.Sprintf("slave %s:%d is already known for %s", n.Host, n.Port, podname)})
+				continue
+			}
+			seenSlaves[key] = true
+		}
+		if remove[podname] {
+			fixes = append(fixes, ConfigFix{line, fmt.Sprintf("pod %s can not authenticate to its duplicated master", podname)})
+		}
+	}
+	return
+}
+
+// UnifiedDiff renders the removal of the lines in fixes as a unified diff with
+// three lines of context.
+func UnifiedDiff(path string, lines []string, fixes []ConfigFix) string {
+	const context = 3
+	if len(fixes) == 0 {
+		return ""
+	}
+	removed := make(map[int]bool)
+	for _, f := range fixes {
+		removed[f.Line] = true
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "--- %s\n+++ %s\n", path, path)
+	i := 0
+	for i < len(lines) {
+		if !removed[i] {
+			i++
+			continue
+		}
+		// Grow the hunk until the gap to the next removal exceeds the context
+		// on both sides.
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for j := i; j < len(lines) && j <= end+2*context; j++ {
+			if removed[j] {
+				end = j
+			}
+		}
+		stop := end + context + 1
+		if stop > len(lines) {
+			stop = len(lines)
+		}
+		oldCount, newCount := stop-start, 0
+		for j := start; j < stop; j++ {
+			if !removed[j] {
+				newCount++
+			}
+		}
+		newStart := start + 1
+		for j := 0; j < start; j++ {
+			if removed[j] {
+				newStart--
+			}
+		}
+		fmt.Fprintf(&b, "@@ -%d,%d +%d,%d @@\n", start+1, oldCount, newStart, newCount)
+		for j := start; j < stop; j++ {
+			if removed[j] {
+				fmt.Fprintf(&b, "-%s\n", lines[j])
+			} else {
+				fmt.Fprintf(&b, " %s\n", lines[j])
+			}
+		}
+		i = stop
+	}
+	return b.String()
+}
+
+// FixConfigFile writes the fixes for the config file at path to w as a diff
+// and, unless dryRun is set, writes the corrected file once confirm agrees.
+// The original is kept alongside as a timestamped backup. The corrected file
+// is written beside the original and renamed over it, so the config is never
+// left half written.
+func (res *AuditResult) FixConfigFile(w io.Writer, path string, dryRun bool, confirm func(prompt string) bool) error {
+	raw, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	content := string(raw)
+	trailingNewline := strings.HasSuffix(content, "\n")
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+
+	conf, err := sentinelconf.Parse(path, bytes.NewReader(raw))
+	if err != nil {
+		return err
+	}
+	fixes := res.PlanConfigFixes(conf)
+	if len(fixes) == 0 {
+		fmt.Fprintln(w, "No fixes needed")
+		return nil
+	}
+	for _, f := range fixes {
+		fmt.Fprintf(w, "line %d: %s\n", f.Line+1, f.Reason)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprint(w, UnifiedDiff(path, lines, fixes))
+	if dryRun {
+		return nil
+	}
+	if !confirm(fmt.Sprintf("Write these changes to %s?", path)) {
+		fmt.Fprintln(w, "Not writing changes")
+		return nil
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	backup := fmt.Sprintf("%s.%s", path, time.Now().Format("20060102-150405"))
+	err = ioutil.WriteFile(backup, raw, info.Mode())
+	if err != nil {
+		return err
+	}
+	removed := make(map[int]bool)
+	for _, f := range fixes {
+		removed[f.Line] = true
+	}
+	var kept []string
+	for i, line := range lines {
+		if !removed[i] {
+			kept = append(kept, line)
+		}
+	}
+	fixed := strings.Join(kept, "\n")
+	if trailingNewline {
+		fixed += "\n"
+	}
+	err = replaceFile(path, []byte(fixed), info.Mode())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Wrote %s, original saved as %s\n", path, backup)
+	return nil
+}
+
+// replaceFile writes data to a temporary file in the directory of path and
+// renames it over path.
+func replaceFile(path string, data []byte, mode os.FileMode) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(mode)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,200 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ExpandConfigPaths turns the configs named on the command line into the
+// files to audit. Each pattern may be a file, a glob or a directory, whose
+// *.conf files are audited. Paths named more than once are audited once.
+func ExpandConfigPaths(patterns []string) ([]string, error) {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			matches, err = filepath.Glob(filepath.Join(pattern, "*.conf"))
+			if err != nil {
+				return nil, err
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no *.conf files in directory %s", pattern)
+			}
+		} else if strings.ContainsAny(pattern, "*?[") {
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("bad config pattern %q: %s", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no configs match %s", pattern)
+			}
+		}
+		for _, path := range matches {
+			if !seen[path] {
+				seen[path] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths, nil
+}
+
+// ConfigConflict is a pod which different configs monitor with different
+// masters. Masters maps each master address to the configs using it.
+type ConfigConflict struct {
+	Pod     string
+	Masters map[string][]string
+}
+
+// FleetResult is what auditing several configs found: the result for each
+// config which could be loaded, the reason each other one could not, and the
+// conflicts between them.
+type FleetResult struct {
+	Results []*AuditResult
+	// Failures maps each config which could not be loaded to the reason.
+	Failures   map[string]string
+	Conflicts  []ConfigConflict
+	ExitStatus int
+}
+
+// AuditConfigs audits each config independently, one after the other, and
+// then checks them against each other. Pods monitored with different masters
+// in different configs get CONFLICTINGMASTER in every config naming them.
+func AuditConfigs(sources []Source, opts Options) *FleetResult {
+	fr := &FleetResult{Failures: make(map[string]string)}
+	for _, source := range sources {
+		if opts.Output != nil {
+			fmt.Fprintf(opts.Output, "==> %s <==\n", source.Path())
+		}
+		res, err := NewAuditor(source, opts).Run()
+		if err != nil {
+			if opts.Output != nil {
+				fmt.Fprintf(opts.Output, "Unable to audit %s: %s\n", source.Path(), err)
+			}
+			fr.Failures[source.Path()] = err.Error()
+			continue
+		}
+		fr.Results = append(fr.Results, res)
+	}
+	fr.findConflictingMasters(newAddressBook(opts.Resolver, opts.Timeout, opts.Offline))
+	for _, res := range fr.Results {
+		fr.ExitStatus |= res.ExitStatus
+	}
+	if len(fr.Failures) > 0 {
+		fr.ExitStatus |= ExitAuditFailure
+	}
+	return fr
+}
+
+// findConflictingMasters looks for pods of the same name whose master
+// address differs between configs. Addresses are compared in canonical form,
+// so a master named by hostname in one config and by IP in another is not a
+// conflict.
+func (fr *FleetResult) findConflictingMasters(b *addressBook) {
+	masters := make(map[string]map[string][]string)
+	for _, res := range fr.Results {
+		for name, pod := range res.Config.ManagedPodConfigs {
+			addr := net.JoinHostPort(b.canonicalHost(pod.IP), strconv.Itoa(pod.Port))
+			if masters[name] == nil {
+				masters[name] = make(map[string][]string)
+			}
+			masters[name][addr] = append(masters[name][addr], res.Path)
+		}
+	}
+	for name, byAddr := range masters {
+		if len(byAddr) > 1 {
+			fr.Conflicts = append(fr.Conflicts, ConfigConflict{Pod: name, Masters: byAddr})
+		}
+	}
+	sort.Slice(fr.Conflicts, func(i, j int) bool { return fr.Conflicts[i].Pod < fr.Conflicts[j].Pod })
+	for _, conflict := range fr.Conflicts {
+		for _, res := range fr.Results {
+			if _, monitored := res.Config.ManagedPodConfigs[conflict.Pod]; monitored {
+				res.addIssue(conflict.Pod, CONFLICTINGMASTER)
+			}
+		}
+	}
+}
+
+// WriteSummary writes the combined summary of every config audited.
+func (fr *FleetResult) WriteSummary(w io.Writer) {
+	fmt.Fprintf(w, "Combined summary of %d configs\n", len(fr.Results)+len(fr.Failures))
+	for _, res := range fr.Results {
+		podsWithIssues := 0
+		for _, pod := range res.Config.ManagedPodConfigs {
+			if len(pod.Issues) > 0 {
+				podsWithIssues++
+			}
+		}
+		fmt.Fprintf(w, "\t%s: sentinel '%s', %d of %d pods have issues, exit status %d\n",
+			res.Path, res.Sentinel, podsWithIssues, len(res.Config.ManagedPodConfigs), res.ExitStatus)
+	}
+	for _, path := range sortedKeys(fr.Failures) {
+		fmt.Fprintf(w, "\t%s: unable to load: %s\n", path, fr.Failures[path])
+	}
+	if len(fr.Conflicts) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Pods monitored with different masters in different configs:")
+		for _, conflict := range fr.Conflicts {
+			var uses []string
+			for _, addr := range sortedAddresses(conflict.Masters) {
+				uses = append(uses, fmt.Sprintf("%s in %s", addr, strings.Join(conflict.Masters[addr], ", ")))
+			}
+			fmt.Fprintf(w, "\t%s: %s\n", conflict.Pod, strings.Join(uses, "; "))
+		}
+	}
+}
+
+func sortedAddresses(m map[string][]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// NagiosReport writes a single status line covering every config, and
+// returns the resulting severity. Configs which could not be loaded make an
+// otherwise clean check UNKNOWN.
+func (fr *FleetResult) NagiosReport(w io.Writer) Severity {
+	var c nagiosCounts
+	for _, res := range fr.Results {
+		c.add(res, true)
+	}
+	if len(fr.Failures) > 0 && c.status == OK {
+		c.status = UNKNOWN
+	}
+	msg := fmt.Sprintf("%d of %d pods in %d configs have configuration issues", c.podsWithIssues, c.pods, len(fr.Results))
+	if c.offline {
+		msg += " (offline, network checks skipped)"
+	}
+	if len(fr.Failures) > 0 {
+		msg += fmt.Sprintf(", unable to load %s", strings.Join(sortedKeys(fr.Failures), ","))
+	}
+	if len(c.summaries) > 0 {
+		msg += ": " + strings.Join(c.summaries, " ")
+	}
+	fmt.Fprintf(w, "SENTINEL CONFIG %s - %s | configs=%d configs_failed=%d pods=%d pods_with_issues=%d sentinels_reachable=%d sentinels_unreachable=%d sentinels_auth_failed=%d\n",
+		c.status, msg, len(fr.Results), len(fr.Failures), c.pods, c.podsWithIssues, c.reachable, c.unreachable, c.authFailed)
+	return c.status
+}
+
+// WriteJSON writes the combined result to w as an indented JSON document.
+func (fr *FleetResult) WriteJSON(w io.Writer) error {
+	enc, err := json.MarshalIndent(fr, "", "  ")
+	if err != nil {
+		return err
+	}
+	enc = append(enc, '\n')
+	_, err = w.Write(enc)
+	return err
+}
@@ -0,0 +1,320 @@
+package audit
+
+import "fmt"
+
+type ConfigIssue int
+
+const (
+	NOTENOUGHSENTINELS ConfigIssue = 1 << iota
+	NOQUORUM
+	NOSLAVES
+	NOVALIDSLAVES
+	HASINVALIDSENTINELS
+	DUPLICATEMASTERIP
+	DUPLICATESLAVEIP
+	PODMISSINGONSENTINEL
+	MASTERMISMATCH
+	QUORUMMISMATCH
+	AUTHMISMATCH
+	MASTERUNREACHABLE
+	MASTERAUTHFAILED
+	MASTERISSLAVE
+	SLAVECOUNTMISMATCH
+	DOWNAFTERTOOLOW
+	DOWNAFTERTOOHIGH
+	FAILOVERTIMEOUTTOOSHORT
+	PARALLELSYNCSALLSLAVES
+	TIMINGPOLICYMISMATCH
+	NOAUTHPASS
+	QUORUMFORMULA
+	QUORUMTOOHIGH
+	QUORUMBELOWMAJORITY
+	EVENSENTINELS
+	CONFLICTINGMASTER
+	SENTINELAUTHFAILED
+	ACLPERMISSIONS
+	HOSTNAMERESOLUTION
+	DUPLICATESENTINEL
+	LOCALADDRESS
+	COLOCATEDNODE
+)
+
+// allIssues lists every ConfigIssue, in bit order, for reports which cover
+// every issue whether or not it was found.
+var allIssues = []ConfigIssue{
+	NOTENOUGHSENTINELS,
+	NOQUORUM,
+	NOSLAVES,
+	NOVALIDSLAVES,
+	HASINVALIDSENTINELS,
+	DUPLICATEMASTERIP,
+	DUPLICATESLAVEIP,
+	PODMISSINGONSENTINEL,
+	MASTERMISMATCH,
+	QUORUMMISMATCH,
+	AUTHMISMATCH,
+	MASTERUNREACHABLE,
+	MASTERAUTHFAILED,
+	MASTERISSLAVE,
+	SLAVECOUNTMISMATCH,
+	DOWNAFTERTOOLOW,
+	DOWNAFTERTOOHIGH,
+	FAILOVERTIMEOUTTOOSHORT,
+	PARALLELSYNCSALLSLAVES,
+	TIMINGPOLICYMISMATCH,
+	NOAUTHPASS,
+	QUORUMFORMULA,
+	QUORUMTOOHIGH,
+	QUORUMBELOWMAJORITY,
+	EVENSENTINELS,
+	CONFLICTINGMASTER,
+	SENTINELAUTHFAILED,
+	ACLPERMISSIONS,
+	HOSTNAMERESOLUTION,
+	DUPLICATESENTINEL,
+	LOCALADDRESS,
+	COLOCATEDNODE,
+}
+
+func (ci ConfigIssue) String() string {
+	s := ""
+	switch ci {
+	case NOTENOUGHSENTINELS:
+		s += "Not Enough Sentinels"
+	case NOQUORUM:
+		s += "NO Quorum Possible"
+	case NOSLAVES:
+		s += "No Slaves Configured/Known"
+	case NOVALIDSLAVES:
+		s += "No Valid Slaves"
+	case HASINVALIDSENTINELS:
+		s += "Has Sentinels Configured which do not exist or are unreachable"
+	case DUPLICATEMASTERIP:
+		s += "Shares a master IP with another pod."
+	case DUPLICATESLAVEIP:
+		s += "Shares a slave IP with another pod."
+	case PODMISSINGONSENTINEL:
+		s += "Not monitored by a sentinel listed as a known-sentinel"
+	case MASTERMISMATCH:
+		s += "Other sentinels disagree about the master's address"
+	case QUORUMMISMATCH:
+		s += "Other sentinels have a different quorum"
+	case AUTHMISMATCH:
+		s += "Other sentinels appear to lack the pod's auth-pass"
+	case MASTERUNREACHABLE:
+		s += "Master is unreachable"
+	case MASTERAUTHFAILED:
+		s += "Unable to authenticate to the master with the pod's auth-pass"
+	case MASTERISSLAVE:
+		s += "Configured master reports role:slave"
+	case SLAVECOUNTMISMATCH:
+		s += "Master's connected slaves differ from the known slaves"
+	case DOWNAFTERTOOLOW:
+		s += "down-after-milliseconds is low enough to cause flappy failovers"
+	case DOWNAFTERTOOHIGH:
+		s += "down-after-milliseconds delays failover too long"
+	case FAILOVERTIMEOUTTOOSHORT:
+		s += "failover-timeout is shorter than a full resync of the master"
+	case PARALLELSYNCSALLSLAVES:
+		s += "parallel-syncs resyncs every slave at once"
+	case TIMINGPOLICYMISMATCH:
+		s += "Failover timing differs from the fleet policy"
+	case NOAUTHPASS:
+		s += "No auth-pass configured"
+	case QUORUMFORMULA:
+		s += "Quorum does not follow the policy's quorum formula"
+	case QUORUMTOOHIGH:
+		s += "Quorum is greater than the number of sentinels"
+	case QUORUMBELOWMAJORITY:
+		s += "Quorum is less than a majority of sentinels"
+	case EVENSENTINELS:
+		s += "Even number of sentinels"
+	case CONFLICTINGMASTER:
+		s += "Another config monitors the pod with a different master"
+	case SENTINELAUTHFAILED:
+		s += "Known sentinels refuse the sentinel credentials"
+	case ACLPERMISSIONS:
+		s += "The auth-user lacks ACL permissions sentinel needs on the master"
+	case HOSTNAMERESOLUTION:
+		s += "A hostname is refused without resolve-hostnames, does not resolve or resolves to several addresses"
+	case DUPLICATESENTINEL:
+		s += "The same sentinel is known under more than one address"
+	case LOCALADDRESS:
+		s += "Master or slave is addressed by loopback, which the other sentinels can not reach, so failover can not work"
+	case COLOCATEDNODE:
+		s += "Master or slave runs on this sentinel's host, so one host failure loses both"
+	}
+	return s
+}
+
+// Name returns the short identifier of the issue. Unlike String it is stable
+// and intended for machine consumption.
+func (ci ConfigIssue) Name() string {
+	switch ci {
+	case NOTENOUGHSENTINELS:
+		return "NOTENOUGHSENTINELS"
+	case NOQUORUM:
+		return "NOQUORUM"
+	case NOSLAVES:
+		return "NOSLAVES"
+	case NOVALIDSLAVES:
+		return "NOVALIDSLAVES"
+	case HASINVALIDSENTINELS:
+		return "HASINVALIDSENTINELS"
+	case DUPLICATEMASTERIP:
+		return "DUPLICATEMASTERIP"
+	case DUPLICATESLAVEIP:
+		return "DUPLICATESLAVEIP"
+	case PODMISSINGONSENTINEL:
+		return "PODMISSINGONSENTINEL"
+	case MASTERMISMATCH:
+		return "MASTERMISMATCH"
+	case QUORUMMISMATCH:
+		return "QUORUMMISMATCH"
+	case AUTHMISMATCH:
+		return "AUTHMISMATCH"
+	case MASTERUNREACHABLE:
+		return "MASTERUNREACHABLE"
+	case MASTERAUTHFAILED:
+		return "MASTERAUTHFAILED"
+	case MASTERISSLAVE:
+		return "MASTERISSLAVE"
+	case SLAVECOUNTMISMATCH:
+		return "SLAVECOUNTMISMATCH"
+	case DOWNAFTERTOOLOW:
+		return "DOWNAFTERTOOLOW"
+	case DOWNAFTERTOOHIGH:
+		return "DOWNAFTERTOOHIGH"
+	case FAILOVERTIMEOUTTOOSHORT:
+		return "FAILOVERTIMEOUTTOOSHORT"
+	case PARALLELSYNCSALLSLAVES:
+		return "PARALLELSYNCSALLSLAVES"
+	case TIMINGPOLICYMISMATCH:
+		return "TIMINGPOLICYMISMATCH"
+	case NOAUTHPASS:
+		return "NOAUTHPASS"
+	case QUORUMFORMULA:
+		return "QUORUMFORMULA"
+	case QUORUMTOOHIGH:
+		return "QUORUMTOOHIGH"
+	case QUORUMBELOWMAJORITY:
+		return "QUORUMBELOWMAJORITY"
+	case EVENSENTINELS:
+		return "EVENSENTINELS"
+	case CONFLICTINGMASTER:
+		return "CONFLICTINGMASTER"
+	case SENTINELAUTHFAILED:
+		return "SENTINELAUTHFAILED"
+	case ACLPERMISSIONS:
+		return "ACLPERMISSIONS"
+	case HOSTNAMERESOLUTION:
+		return "HOSTNAMERESOLUTION"
+	case DUPLICATESENTINEL:
+		return "DUPLICATESENTINEL"
+	case LOCALADDRESS:
+		return "LOCALADDRESS"
+	case COLOCATEDNODE:
+		return "COLOCATEDNODE"
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", int(ci))
+}
+
+// MarshalText encodes the issue as its Name, so JSON output carries readable
+// issue identifiers rather than bit values.
+func (ci ConfigIssue) MarshalText() ([]byte, error) {
+	return []byte(ci.Name()), nil
+}
+
+// The exit status sets one bit for each class of issue found. The classes
+// group issues by what has to be fixed, so a script can tell, say, a timing
+// problem from an unreachable master.
+const (
+	// ExitSentinels is set when the pod has too few or an even number of
+	// sentinels, or known sentinels which do not answer or do not monitor
+	// the pod.
+	ExitSentinels = 1 << iota
+	// ExitQuorum is set when the quorum can not be reached, is set wrong or
+	// differs between sentinels.
+	ExitQuorum
+	// ExitSlaves is set when the pod has no slaves, or they are not
+	// replicating from its master.
+	ExitSlaves
+	// ExitMaster is set when the master is unreachable, is not a master or
+	// sentinels and configs disagree on which it is.
+	ExitMaster
+	// ExitAuth is set when credentials are missing, refused or lack
+	// permissions.
+	ExitAuth
+	// ExitAddressing is set when nodes are shared between pods, named by
+	// hostnames which do not resolve to one address, or addressed as the
+	// sentinel's own host.
+	ExitAddressing
+	// ExitTiming is set when failover timing is unsafe or differs from the
+	// policy.
+	ExitTiming
+)
+
+// ExitAuditFailure is the exit status used when the audit itself could not be
+// completed, for example because the config file could not be loaded. It is
+// outside the range of values produced by ExitStatus.
+const ExitAuditFailure = 128
+
+// exitClass maps every issue to its exit status class.
+var exitClass = map[ConfigIssue]int{
+	NOTENOUGHSENTINELS:      ExitSentinels,
+	EVENSENTINELS:           ExitSentinels,
+	HASINVALIDSENTINELS:     ExitSentinels,
+	PODMISSINGONSENTINEL:    ExitSentinels,
+	NOQUORUM:                ExitQuorum,
+	QUORUMMISMATCH:          ExitQuorum,
+	QUORUMFORMULA:           ExitQuorum,
+	QUORUMTOOHIGH:           ExitQuorum,
+	QUORUMBELOWMAJORITY:     ExitQuorum,
+	NOSLAVES:                ExitSlaves,
+	NOVALIDSLAVES:           ExitSlaves,
+	SLAVECOUNTMISMATCH:      ExitSlaves,
+	MASTERUNREACHABLE:       ExitMaster,
+	MASTERISSLAVE:           ExitMaster,
+	MASTERMISMATCH:          ExitMaster,
+	CONFLICTINGMASTER:       ExitMaster,
+	NOAUTHPASS:              ExitAuth,
+	MASTERAUTHFAILED:        ExitAuth,
+	SENTINELAUTHFAILED:      ExitAuth,
+	AUTHMISMATCH:            ExitAuth,
+	ACLPERMISSIONS:          ExitAuth,
+	DUPLICATEMASTERIP:       ExitAddressing,
+	DUPLICATESLAVEIP:        ExitAddressing,
+	DUPLICATESENTINEL:       ExitAddressing,
+	HOSTNAMERESOLUTION:      ExitAddressing,
+	LOCALADDRESS:            ExitAddressing,
+	COLOCATEDNODE:           ExitAddressing,
+	DOWNAFTERTOOLOW:         ExitTiming,
+	DOWNAFTERTOOHIGH:        ExitTiming,
+	FAILOVERTIMEOUTTOOSHORT: ExitTiming,
+	PARALLELSYNCSALLSLAVES:  ExitTiming,
+	TIMINGPOLICYMISMATCH:    ExitTiming,
+}
+
+// ExitStatus returns the process exit status for a set of issues: the bitwise
+// OR of the class of every issue found, so a clean audit exits 0:
+//
+//	1  sentinels: NOTENOUGHSENTINELS, EVENSENTINELS, HASINVALIDSENTINELS,
+//	   PODMISSINGONSENTINEL
+//	2  quorum: NOQUORUM, QUORUMMISMATCH, QUORUMFORMULA, QUORUMTOOHIGH,
+//	   QUORUMBELOWMAJORITY
+//	4  slaves: NOSLAVES, NOVALIDSLAVES, SLAVECOUNTMISMATCH
+//	8  master: MASTERUNREACHABLE, MASTERISSLAVE, MASTERMISMATCH,
+//	   CONFLICTINGMASTER
+//	16 auth: NOAUTHPASS, MASTERAUTHFAILED, SENTINELAUTHFAILED, AUTHMISMATCH,
+//	   ACLPERMISSIONS
+//	32 addressing: DUPLICATEMASTERIP, DUPLICATESLAVEIP, DUPLICATESENTINEL,
+//	   HOSTNAMERESOLUTION, LOCALADDRESS, COLOCATEDNODE
+//	64 timing: DOWNAFTERTOOLOW, DOWNAFTERTOOHIGH, FAILOVERTIMEOUTTOOSHORT,
+//	   PARALLELSYNCSALLSLAVES, TIMINGPOLICYMISMATCH
+func ExitStatus(issues []ConfigIssue) int {
+	status := 0
+	for _, issue := range issues {
+		status |= exitClass[issue]
+	}
+	return status
+}
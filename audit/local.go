@@ -0,0 +1,104 @@
+package audit
+
+import (
+	"net"
+	"strings"
+)
+
+// localAddresses are the addresses by which the sentinel's host reaches
+// itself: loopback, the sentinel's bind address and the host's interface
+// addresses.
+type localAddresses struct {
+	bind       string
+	interfaces map[string]bool
+}
+
+// newLocalAddresses collects the local addresses of a sentinel bound to bind.
+// When the interfaces can not be listed only loopback and bind are known, and
+// the error says why.
+func newLocalAddresses(bind string) (localAddresses, error) {
+	l := localAddresses{interfaces: make(map[string]bool)}
+	if ip := net.ParseIP(bind); ip != nil && !ip.IsUnspecified() {
+		l.bind = ip.String()
+	}
+	addrs, err := net.InterfaceAddrs()
+	for _, addr := range addrs {
+		if ipnet, ok := addr.(*net.IPNet); ok {
+			l.interfaces[ipnet.IP.String()] = true
+		}
+	}
+	return l, err
+}
+
+// isLoopback reports whether host is localhost or resolves to a loopback
+// address.
+func isLoopback(host string, b *addressBook) bool {
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	addrs, _ := b.resolve(host)
+	for _, addr := range addrs {
+		if ip := net.ParseIP(addr); ip != nil && ip.IsLoopback() {
+			return true
+		}
+	}
+	return false
+}
+
+// why returns which of the sentinel's own addresses host is, other than
+// loopback, or "" if it is none of them.
+func (l localAddresses) why(host string, b *addressBook) string {
+	addrs, _ := b.resolve(host)
+	for _, addr := range addrs {
+		switch {
+		case addr == l.bind:
+			return "the sentinel's bind address"
+		case l.interfaces[addr]:
+			return "an address of this host"
+		}
+	}
+	return ""
+}
+
+// isLocal reports whether host is the sentinel's own host.
+func (l localAddresses) isLocal(host string, b *addressBook) bool {
+	return isLoopback(host, b) || l.why(host, b) != ""
+}
+
+// checkLocalAddresses records the pod's master and slaves which run on the
+// sentinel's own host while other sentinels of the pod run elsewhere. Those
+// addressed by loopback can not be reached by the other sentinels, which
+// take the address to be their own host, so failover can not work. Those
+// addressed by the bind or an interface address are reachable, but share a
+// failure domain with this sentinel: losing the host loses a sentinel vote
+// and the node together. Pods whose sentinels all run on this host, such as
+// a test setup, are not affected.
+func (pc *SentinelPodConfig) checkLocalAddresses(l localAddresses, b *addressBook) {
+	pc.LocalAddresses = nil
+	pc.ColocatedNodes = nil
+	remote := false
+	for sentinel := range pc.Sentinels {
+		host, _ := splitAddr(sentinel)
+		if !l.isLocal(host, b) {
+			remote = true
+			break
+		}
+	}
+	if !remote {
+		return
+	}
+	check := func(role, addr, host, promoted string) {
+		if isLoopback(host, b) {
+			pc.LocalAddresses = append(pc.LocalAddresses, role+" "+addr+
+				" is a loopback address, which other sentinels cannot reach"+promoted)
+		} else if why := l.why(host, b); why != "" {
+			pc.ColocatedNodes = append(pc.ColocatedNodes, role+" "+addr+" is "+why+
+				", so losing this host loses a sentinel and the "+role+" together")
+		}
+	}
+	check("master", pc.IP, pc.IP, "")
+	for _, slave := range pc.Slaves {
+		host, _ := splitAddr(slave)
+		check("slave", slave, host, " once it is promoted")
+	}
+}
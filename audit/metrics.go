@@ -0,0 +1,135 @@
+package audit
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// metricsExporter holds the most recently rendered metrics page. Audits run
+// in their own goroutine while the page is served concurrently.
+type metricsExporter struct {
+	auditor     *Auditor
+	mu          sync.RWMutex
+	page        []byte
+	pods        map[string]SentinelPodConfig
+	lastSuccess time.Time
+}
+
+// ServeMetrics audits the config every interval and serves the results as
+// Prometheus metrics on addr. It only returns if the listener fails.
+func (a *Auditor) ServeMetrics(addr string, interval time.Duration) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", a.MetricsHandler(interval, stop))
+	log.Printf("Serving metrics on %s/metrics", addr)
+	return http.ListenAndServe(addr, mux)
+}
+
+// MetricsHandler audits the config, then again every interval until stop is
+// closed, and returns a handler serving the results of the latest audit as
+// Prometheus metrics, for callers mounting it on their own server.
+func (a *Auditor) MetricsHandler(interval time.Duration, stop <-chan struct{}) http.Handler {
+	e := &metricsExporter{auditor: a}
+	e.audit()
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				e.audit()
+			}
+		}
+	}()
+	return e
+}
+
+func (e *metricsExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(e.page)
+}
+
+// audit reloads the config and runs the reports. When the config cannot be
+// loaded the pod metrics from the last successful audit are kept.
+func (e *metricsExporter) audit() {
+	start := time.Now()
+	res, err := e.auditor.Run()
+	if err != nil {
+		log.Printf("Audit failed: %s", err)
+	}
+	duration := time.Since(start)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if err == nil {
+		e.pods = res.Config.ManagedPodConfigs
+		e.lastSuccess = time.Now()
+	}
+	e.page = renderMetrics(e.pods, err == nil, duration, e.lastSuccess)
+}
+
+func renderMetrics(pods map[string]SentinelPodConfig, success bool, duration time.Duration, lastSuccess time.Time) []byte {
+	var b bytes.Buffer
+	var names []string
+	for name := range pods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	podGauge := func(metric, help string, value func(SentinelPodConfig) int) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", metric, help, metric)
+		for _, name := range names {
+			fmt.Fprintf(&b, "%s{pod=\"%s\"} %d\n", metric, escapeLabel(name), value(pods[name]))
+		}
+	}
+	podGauge("sentinel_audit_pod_quorum", "Configured quorum of the pod.",
+		func(pc SentinelPodConfig) int { return pc.Quorum })
+	podGauge("sentinel_audit_pod_sentinels", "Sentinels configured for the pod.",
+		func(pc SentinelPodConfig) int { return len(pc.Sentinels) })
+	podGauge("sentinel_audit_pod_confirmed_sentinels", "Configured sentinels which were reachable.",
+		func(pc SentinelPodConfig) int { return len(pc.ConfirmedSentinels) })
+	podGauge("sentinel_audit_pod_known_slaves", "Slaves known for the pod.",
+		func(pc SentinelPodConfig) int { return len(pc.Slaves) })
+	podGauge("sentinel_audit_pod_confirmed_slaves", "Known slaves which are replicating from the pod's master.",
+		func(pc SentinelPodConfig) int { return len(pc.ConfirmedSlaves) })
+
+	fmt.Fprintf(&b, "# HELP sentinel_audit_pod_issue Whether the pod has the configuration issue.\n# TYPE sentinel_audit_pod_issue gauge\n")
+	for _, name := range names {
+		for _, issue := range allIssues {
+			has := 0
+			for _, i := range pods[name].Issues {
+				if i == issue {
+					has = 1
+				}
+			}
+			fmt.Fprintf(&b, "sentinel_audit_pod_issue{pod=\"%s\",issue=\"%s\"} %d\n", escapeLabel(name), issue.Name(), has)
+		}
+	}
+
+	ok := 0
+	if success {
+		ok = 1
+	}
+	fmt.Fprintf(&b, "# HELP sentinel_audit_success Whether the last audit could load the config.\n# TYPE sentinel_audit_success gauge\nsentinel_audit_success %d\n", ok)
+	fmt.Fprintf(&b, "# HELP sentinel_audit_duration_seconds Duration of the last audit.\n# TYPE sentinel_audit_duration_seconds gauge\nsentinel_audit_duration_seconds %f\n", duration.Seconds())
+	if !lastSuccess.IsZero() {
+		fmt.Fprintf(&b, "# HELP sentinel_audit_last_success_timestamp_seconds Time of the last successful audit.\n# TYPE sentinel_audit_last_success_timestamp_seconds gauge\nsentinel_audit_last_success_timestamp_seconds %d\n", lastSuccess.Unix())
+	}
+	return b.Bytes()
+}
+
+// escapeLabel escapes a label value for the Prometheus text format.
+func escapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Severity is a Nagios plugin status. Its value is the plugin exit status.
+type Severity int
+
+const (
+	OK Severity = iota
+	WARNING
+	CRITICAL
+	UNKNOWN
+)
+
+func (s Severity) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case WARNING:
+		return "WARNING"
+	case CRITICAL:
+		return "CRITICAL"
+	}
+	return "UNKNOWN"
+}
+
+// Severity returns how serious the issue is when reported as a Nagios check.
+// Issues which prevent a correct failover are critical, the rest are
+// warnings.
+func (ci ConfigIssue) Severity() Severity {
+	switch ci {
+	case NOQUORUM, NOVALIDSLAVES, DUPLICATEMASTERIP, MASTERMISMATCH,
+		MASTERUNREACHABLE, MASTERAUTHFAILED, MASTERISSLAVE, QUORUMTOOHIGH, CONFLICTINGMASTER,
+		ACLPERMISSIONS, HOSTNAMERESOLUTION, LOCALADDRESS:
+		return CRITICAL
+	case NOTENOUGHSENTINELS, NOSLAVES, HASINVALIDSENTINELS, DUPLICATESLAVEIP,
+		PODMISSINGONSENTINEL, QUORUMMISMATCH, AUTHMISMATCH, SLAVECOUNTMISMATCH,
+		DOWNAFTERTOOLOW, DOWNAFTERTOOHIGH, FAILOVERTIMEOUTTOOSHORT, PARALLELSYNCSALLSLAVES,
+		TIMINGPOLICYMISMATCH, NOAUTHPASS, QUORUMFORMULA, QUORUMBELOWMAJORITY, EVENSENTINELS,
+		SENTINELAUTHFAILED, DUPLICATESENTINEL, COLOCATEDNODE:
+		return WARNING
+	}
+	return UNKNOWN
+}
+
+// nagiosCounts is what a Nagios status line reports about one or more
+// audit results.
+type nagiosCounts struct {
+	status         Severity
+	summaries      []string
+	pods           int
+	podsWithIssues int
+	reachable      int
+	unreachable    int
+	authFailed     int
+	offline        bool
+}
+
+// add counts the pods, issues and sentinels of res. When qualify is set the
+// pods are named with the path of their config, so pods of the same name in
+// different configs can be told apart.
+func (c *nagiosCounts) add(res *AuditResult, qualify bool) {
+	c.pods += len(res.Config.ManagedPodConfigs)
+	for _, pod := range res.Config.ManagedPodConfigs {
+		if len(pod.Issues) > 0 {
+			c.podsWithIssues++
+		}
+	}
+	for _, issue := range res.Issues() {
+		if issue.Severity() > c.status {
+			c.status = issue.Severity()
+		}
+		pods := res.PodsByIssue[issue]
+		if qualify {
+			pods = make([]string, len(res.PodsByIssue[issue]))
+			for i, pod := range res.PodsByIssue[issue] {
+				pods[i] = res.Path + ":" + pod
+			}
+		}
+		c.summaries = append(c.summaries, fmt.Sprintf("%s(%s)", issue.Name(), strings.Join(pods, ",")))
+	}
+	if res.Skipped != nil {
+		// Offline, the sentinels were never probed.
+		c.offline = true
+		return
+	}
+	for s := range res.Config.KnownSentinels {
+		if res.isUnreachable(s) {
+			c.unreachable++
+		} else if _, refused := res.AuthFailedSentinels[s]; refused {
+			c.authFailed++
+		} else {
+			c.reachable++
+		}
+	}
+}
+
+// NagiosReport writes the single status line and perfdata for the issues
+// found by the reports, and returns the resulting severity.
+func (res *AuditResult) NagiosReport(w io.Writer) Severity {
+	var c nagiosCounts
+	c.add(res, false)
+	msg := fmt.Sprintf("%d of %d pods have configuration issues", c.podsWithIssues, c.pods)
+	if c.offline {
+		msg += " (offline, network checks skipped)"
+	}
+	if len(c.summaries) > 0 {
+		msg += ": " + strings.Join(c.summaries, " ")
+	}
+	fmt.Fprintf(w, "SENTINEL CONFIG %s - %s | pods=%d pods_with_issues=%d sentinels_reachable=%d sentinels_unreachable=%d sentinels_auth_failed=%d\n",
+		c.status, msg, c.pods, c.podsWithIssues, c.reachable, c.unreachable, c.authFailed)
+	return c.status
+}
+
+type byIssue []ConfigIssue
+
+func (b byIssue) Len() int           { return len(b) }
+func (b byIssue) Less(i, j int) bool { return b[i] < b[j] }
+func (b byIssue) Swap(i, j int)      { b[i], b[j] = b[j], b[i] }
@@ -0,0 +1,169 @@
+package audit
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/therealbill/libredis/info"
+)
+
+// ConfigIssues derives the pod's issues from its config and the state its
+// validation left behind, judged against its policy. local is the address of
+// the sentinel whose config the pod came from. Pods which have not been
+// validated only get the issues visible in the config itself.
+func (pc *SentinelPodConfig) ConfigIssues(pp PodPolicy, local string) (issues []ConfigIssue) {
+	if len(pc.InvalidSentinels) > 0 {
+		issues = append(issues, HASINVALIDSENTINELS)
+	}
+	if len(pc.AuthFailedSentinels) > 0 {
+		issues = append(issues, SENTINELAUTHFAILED)
+	}
+	if len(pc.HostnameProblems) > 0 {
+		issues = append(issues, HOSTNAMERESOLUTION)
+	}
+	if len(pc.DuplicateSentinels) > 0 {
+		issues = append(issues, DUPLICATESENTINEL)
+	}
+	if len(pc.LocalAddresses) > 0 {
+		issues = append(issues, LOCALADDRESS)
+	}
+	if len(pc.ColocatedNodes) > 0 {
+		issues = append(issues, COLOCATEDNODE)
+	}
+	quorum, _ := pc.quorumIssues(pp, local)
+	issues = append(issues, quorum...)
+	if len(pc.Slaves) < pp.MinSlaves {
+		issues = append(issues, NOSLAVES)
+	} else if pc.Validated && len(pc.Slaves) > 0 && len(pc.ConfirmedSlaves) == 0 {
+		issues = append(issues, NOVALIDSLAVES)
+	}
+	if pc.Master.Checked {
+		switch {
+		case pc.Master.AuthFailed:
+			issues = append(issues, MASTERAUTHFAILED)
+		case pc.Master.Error != "":
+			issues = append(issues, MASTERUNREACHABLE)
+		case pc.Master.Role != "master":
+			issues = append(issues, MASTERISSLAVE)
+		case pc.Master.ConnectedSlaves != len(pc.Slaves):
+			issues = append(issues, SLAVECOUNTMISMATCH)
+		}
+		if pc.AuthUser != "" && pc.Master.Error == "" &&
+			(len(pc.Master.MissingPermissions) > 0 || pc.Master.ACLUser != "" && pc.Master.ACLUser != pc.AuthUser) {
+			issues = append(issues, ACLPERMISSIONS)
+		}
+	}
+	timing, _ := pc.timingIssues(pp)
+	issues = append(issues, timing...)
+	policed, _ := pc.policyIssues(pp, local)
+	issues = append(issues, policed...)
+	return
+}
+
+// validateMaster connects to the pod's master using its auth-user and auth
+// token and records the role and connected slave count it reports. With an
+// auth-user it also checks the user's ACL permissions.
+func (pc *SentinelPodConfig) validateMaster(prober *Prober) {
+	pc.Master = MasterStatus{Checked: true}
+	addr := fmt.Sprintf("%s:%d", pc.IP, pc.Port)
+	conn, err := prober.dial(addr, pc.credentials())
+	if err == nil {
+		defer conn.ClosePool()
+		var nodeinfo info.RedisInfoAll
+		nodeinfo, err = conn.Info()
+		if err == nil {
+			pc.Master.Role = nodeinfo.Replication.Role
+			pc.Master.ConnectedSlaves = nodeinfo.Replication.ConnectedSlaves
+			pc.Master.UsedMemory = nodeinfo.Memory.UsedMemory
+			if pc.AuthUser != "" {
+				pc.checkACL(conn)
+			}
+			return
+		}
+	}
+	pc.Master.Error = err.Error()
+	pc.Master.AuthFailed = isAuthError(err)
+}
+
+// checkSlave connects to the slave at addr using the pod's credentials and
+// returns why it can not act as a replica of the pod's master, or nil if it
+// can. The master the slave reports is compared by canonical address, so a
+// slave naming the master by IP replicates from a master the config names by
+// hostname.
+func (pc *SentinelPodConfig) checkSlave(addr string, prober *Prober, b *addressBook) error {
+	conn, err := prober.dial(addr, pc.credentials())
+	if err != nil {
+		return err
+	}
+	defer conn.ClosePool()
+	raw, err := conn.InfoString("replication")
+	if err != nil {
+		return err
+	}
+	repl := info.BuildMapFromInfoString(raw)
+	if repl["role"] != "slave" {
+		return fmt.Errorf("reports role:%s", repl["role"])
+	}
+	master := fmt.Sprintf("%s:%s", repl["master_host"], repl["master_port"])
+	if b.canonical(master) != b.canonical(fmt.Sprintf("%s:%d", pc.IP, pc.Port)) {
+		return fmt.Errorf("replicates from %s, not %s:%d", master, pc.IP, pc.Port)
+	}
+	if repl["master_link_status"] != "up" {
+		return fmt.Errorf("reports master_link_status:%s", repl["master_link_status"])
+	}
+	return nil
+}
+
+// validatePodSlaves checks every known slave of the pod concurrently.
+func (pc *SentinelPodConfig) validatePodSlaves(prober *Prober, b *addressBook) {
+	if pc.ConfirmedSlaves == nil {
+		pc.ConfirmedSlaves = make(map[string]string)
+	}
+	if pc.InvalidSlaves == nil {
+		pc.InvalidSlaves = make(map[string]string)
+	}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, slave := range pc.Slaves {
+		wg.Add(1)
+		go func(slave string) {
+			defer wg.Done()
+			var err error
+			prober.Run(func() { err = pc.checkSlave(slave, prober, b) })
+			mu.Lock()
+			defer mu.Unlock()
+			if err == nil {
+				pc.ConfirmedSlaves[slave] = ""
+			} else {
+				pc.InvalidSlaves[slave] = err.Error()
+			}
+		}(slave)
+	}
+	wg.Wait()
+}
+
+// validatePodSentinels probes the pod's known sentinels, sorting them into
+// confirmed, invalid and those which refused our credentials.
+func (pc *SentinelPodConfig) validatePodSentinels(prober *Prober) {
+	if pc.ConfirmedSentinels == nil {
+		pc.ConfirmedSentinels = make(map[string]string)
+	}
+	if pc.InvalidSentinels == nil {
+		pc.InvalidSentinels = make(map[string]string)
+	}
+	if pc.AuthFailedSentinels == nil {
+		pc.AuthFailedSentinels = make(map[string]string)
+	}
+	failed := prober.ProbeAll(sortedKeys(pc.Sentinels))
+	for sentinel := range pc.Sentinels {
+		err, invalid := failed[sentinel]
+		switch {
+		case !invalid:
+			pc.ConfirmedSentinels[sentinel] = ""
+		case isAuthError(err):
+			pc.AuthFailedSentinels[sentinel] = err.Error()
+		default:
+			pc.InvalidSentinels[sentinel] = ""
+		}
+	}
+}
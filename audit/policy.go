@@ -0,0 +1,171 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+)
+
+// PodPolicy holds the thresholds and expectations the checks of a pod read.
+// Expected values of 0 are not checked.
+type PodPolicy struct {
+	// MinSentinels is the fewest sentinels a pod may have, counting the
+	// local one. Fewer than three are always reported.
+	MinSentinels int
+	// QuorumFormula constrains the quorum relative to the number of
+	// sentinels: "" allows any quorum, "majority" requires exactly a
+	// majority and "at-least-majority" requires a majority or more.
+	QuorumFormula string
+	// MinSlaves is the fewest known slaves a pod may have.
+	MinSlaves int
+	// RequireAuth requires every pod to have an auth-pass.
+	RequireAuth bool
+
+	MinDownAfter         int
+	MaxDownAfter         int
+	MinFailoverTimeout   int
+	ResyncBytesPerSecond int
+
+	DownAfter       int
+	FailoverTimeout int
+	ParallelSyncs   int
+}
+
+// PolicyOverride replaces parts of the policy for the pods whose names match
+// the Pods glob. Only the fields present in Policy are replaced.
+type PolicyOverride struct {
+	Pods   string
+	Policy json.RawMessage
+}
+
+// Policy is the declarative set of expectations the audit checks against. It
+// is read from the JSON file given with -policy; the PodPolicy fields apply to
+// every pod unless overridden.
+type Policy struct {
+	// AllowedPorts are the ports the local sentinel may listen on.
+	AllowedPorts []int
+	PodPolicy
+	Overrides []PolicyOverride
+}
+
+var validQuorumFormulas = map[string]bool{
+	"":                  true,
+	"majority":          true,
+	"at-least-majority": true,
+}
+
+// DefaultPolicy returns the policy used when no -policy file is given.
+func DefaultPolicy() Policy {
+	return Policy{
+		AllowedPorts: []int{26379},
+		PodPolicy: PodPolicy{
+			MinSlaves:            1,
+			MinDownAfter:         5000,
+			MaxDownAfter:         120000,
+			MinFailoverTimeout:   60000,
+			ResyncBytesPerSecond: 50 * 1024 * 1024,
+		},
+	}
+}
+
+// LoadPolicy reads a policy file. Settings it does not mention keep their
+// DefaultPolicy values.
+func LoadPolicy(filename string) (Policy, error) {
+	p := DefaultPolicy()
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return p, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.DisallowUnknownFields()
+	err = dec.Decode(&p)
+	if err != nil {
+		return p, fmt.Errorf("%s: %s", filename, err)
+	}
+	err = p.validate()
+	if err != nil {
+		return p, fmt.Errorf("%s: %s", filename, err)
+	}
+	return p, nil
+}
+
+func (pp PodPolicy) validate() error {
+	if !validQuorumFormulas[pp.QuorumFormula] {
+		return fmt.Errorf("unknown QuorumFormula '%s'", pp.QuorumFormula)
+	}
+	if pp.ResyncBytesPerSecond <= 0 {
+		return fmt.Errorf("ResyncBytesPerSecond must be positive")
+	}
+	return nil
+}
+
+func (p Policy) validate() error {
+	err := p.PodPolicy.validate()
+	if err != nil {
+		return err
+	}
+	for _, o := range p.Overrides {
+		if _, err := path.Match(o.Pods, ""); err != nil {
+			return fmt.Errorf("override '%s': %s", o.Pods, err)
+		}
+		pp := p.PodPolicy
+		dec := json.NewDecoder(bytes.NewReader(o.Policy))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&pp); err != nil {
+			return fmt.Errorf("override '%s': %s", o.Pods, err)
+		}
+		if err := pp.validate(); err != nil {
+			return fmt.Errorf("override '%s': %s", o.Pods, err)
+		}
+	}
+	return nil
+}
+
+// ForPod returns the policy for the named pod: the defaults with every
+// matching override applied in order.
+func (p Policy) ForPod(name string) PodPolicy {
+	pp := p.PodPolicy
+	for _, o := range p.Overrides {
+		if matched, _ := path.Match(o.Pods, name); matched {
+			// Validated by LoadPolicy.
+			json.Unmarshal(o.Policy, &pp)
+		}
+	}
+	return pp
+}
+
+// AllowsPort reports whether the local sentinel may listen on port.
+func (p Policy) AllowsPort(port int) bool {
+	for _, allowed := range p.AllowedPorts {
+		if port == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// policyIssues checks the pod against the parts of its policy which are not
+// covered by the other checks, returning the issues found and an explanation
+// of each.
+func (pc *SentinelPodConfig) policyIssues(pp PodPolicy, local string) (issues []ConfigIssue, reasons []string) {
+	if pp.RequireAuth && pc.AuthToken == "" {
+		issues = append(issues, NOAUTHPASS)
+		reasons = append(reasons, "no auth-pass is configured but the policy requires one")
+	}
+	total := pc.totalSentinels(local)
+	switch pp.QuorumFormula {
+	case "majority":
+		if pc.Quorum != majority(total) {
+			issues = append(issues, QUORUMFORMULA)
+			reasons = append(reasons, fmt.Sprintf("quorum %d is not the majority, %d, of %d sentinels", pc.Quorum, majority(total), total))
+		}
+	case "at-least-majority":
+		if pc.Quorum < majority(total) {
+			issues = append(issues, QUORUMFORMULA)
+			reasons = append(reasons, fmt.Sprintf("quorum %d is less than the majority, %d, of %d sentinels", pc.Quorum, majority(total), total))
+		}
+	}
+	return
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/therealbill/libredis/client"
+)
+
+// Prober checks whether sentinels answer, running at most Parallelism network
+// operations at once. The result for each address is remembered for the rest
+// of the run, and concurrent probes of the same address share one dial. It is
+// safe to use from multiple goroutines.
+type Prober struct {
+	Parallelism int
+	Timeout     time.Duration
+	// Auth is used for every connection to a sentinel.
+	Auth Credentials
+	// TLS, when set, makes every connection, to sentinels and nodes alike,
+	// over TLS.
+	TLS *client.TLSOptions
+
+	slots   chan struct{}
+	mu      sync.Mutex
+	results map[string]*probeResult
+}
+
+// probeResult is the outcome of probing one address. done is closed once err
+// is set.
+type probeResult struct {
+	done chan struct{}
+	err  error
+}
+
+// NewProber returns a Prober running at most parallelism operations at once,
+// each dial giving up after timeout.
+func NewProber(parallelism int, timeout time.Duration) *Prober {
+	if parallelism < 1 {
+		parallelism = 1
+	}
+	return &Prober{
+		Parallelism: parallelism,
+		Timeout:     timeout,
+		slots:       make(chan struct{}, parallelism),
+		results:     make(map[string]*probeResult),
+	}
+}
+
+// Run calls fn once one of the Parallelism slots is free. Everything which
+// talks to a node during an audit, hostname lookups included, goes through
+// Run or is done one at a time, so the limit holds across all of them. fn
+// must not call Run or Probe itself.
+func (p *Prober) Run(fn func()) {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+	fn()
+}
+
+// Probe dials the sentinel at addr and pings it, returning why it can not be
+// used or nil if it answered. A sentinel which refuses the credentials
+// answered, but fails with an error for which isAuthError is true. Only the
+// first probe of an address touches the network; later ones, including those
+// made while it is still running, get the same result.
+func (p *Prober) Probe(addr string) error {
+	p.mu.Lock()
+	r, probed := p.results[addr]
+	if !probed {
+		r = &probeResult{done: make(chan struct{})}
+		p.results[addr] = r
+	}
+	p.mu.Unlock()
+	if probed {
+		<-r.done
+		return r.err
+	}
+	p.Run(func() { r.err = p.ping(addr) })
+	close(r.done)
+	return r.err
+}
+
+func (p *Prober) ping(addr string) error {
+	conn, err := p.dialSentinel(addr)
+	if err != nil {
+		return err
+	}
+	defer conn.ClosePool()
+	// Ping does not treat an error reply, such as NOAUTH from a sentinel
+	// which wants a password, as an error.
+	rp, err := conn.ExecuteCommand("PING")
+	if err != nil {
+		return err
+	}
+	if rp.Type == client.ErrorReply {
+		return errors.New(rp.Error)
+	}
+	return nil
+}
+
+// ProbeAll probes every address concurrently and returns the error for each
+// one which did not answer.
+func (p *Prober) ProbeAll(addrs []string) map[string]error {
+	failed := make(map[string]error)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			if err := p.Probe(addr); err != nil {
+				mu.Lock()
+				failed[addr] = err
+				mu.Unlock()
+			}
+		}(addr)
+	}
+	wg.Wait()
+	return failed
+}
+
+// Unreachable returns the addresses probed so far which did not answer, with
+// the reason for each. Probes still running are left out.
+func (p *Prober) Unreachable() map[string]string {
+	return p.failures(false)
+}
+
+// AuthFailed returns the addresses probed so far which answered but refused
+// the credentials, with the reason for each.
+func (p *Prober) AuthFailed() map[string]string {
+	return p.failures(true)
+}
+
+func (p *Prober) failures(auth bool) map[string]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	failed := make(map[string]string)
+	for addr, r := range p.results {
+		select {
+		case <-r.done:
+			if r.err != nil && isAuthError(r.err) == auth {
+				failed[addr] = r.err.Error()
+			}
+		default:
+		}
+	}
+	return failed
+}
+
+// IsUnreachable reports whether addr has been probed and did not answer.
+func (p *Prober) IsUnreachable(addr string) bool {
+	p.mu.Lock()
+	r, probed := p.results[addr]
+	p.mu.Unlock()
+	if !probed {
+		return false
+	}
+	select {
+	case <-r.done:
+		return r.err != nil && !isAuthError(r.err)
+	default:
+		return false
+	}
+}
+
+// sortedKeys returns the keys of m in order.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,65 @@
+package audit
+
+import "fmt"
+
+// minSentinels is the fewest sentinels which can survive the loss of one and
+// still authorize a failover.
+const minSentinels = 3
+
+// majority is the smallest number of sentinels which is more than half of n.
+func majority(n int) int {
+	return n/2 + 1
+}
+
+// totalSentinels is the number of sentinels monitoring the pod, counting the
+// local one, at address local, whether or not it is listed among the known
+// sentinels.
+func (pc *SentinelPodConfig) totalSentinels(local string) int {
+	total := len(pc.Sentinels)
+	if _, listed := pc.Sentinels[local]; !listed {
+		total++
+	}
+	return total
+}
+
+// reachableSentinels is the number of the pod's sentinels which answered,
+// counting the local one unless it is listed and did not. Sentinels which
+// refused our credentials are up, and still count towards the quorum.
+func (pc *SentinelPodConfig) reachableSentinels(local string) int {
+	reachable := len(pc.ConfirmedSentinels) + len(pc.AuthFailedSentinels)
+	if _, listed := pc.Sentinels[local]; !listed {
+		reachable++
+	}
+	return reachable
+}
+
+// quorumIssues checks the pod's quorum against the number of sentinels which
+// monitor it and can be reached, returning the issues found and an explanation
+// of each.
+func (pc *SentinelPodConfig) quorumIssues(pp PodPolicy, local string) (issues []ConfigIssue, reasons []string) {
+	add := func(issue ConfigIssue, format string, args ...interface{}) {
+		issues = append(issues, issue)
+		reasons = append(reasons, fmt.Sprintf(format, args...))
+	}
+	total := pc.totalSentinels(local)
+	reachable := pc.reachableSentinels(local)
+	switch {
+	case total < minSentinels:
+		add(NOTENOUGHSENTINELS, "%d sentinels can not survive losing one, at least %d are needed", total, minSentinels)
+	case total < pp.MinSentinels:
+		add(NOTENOUGHSENTINELS, "%d sentinels is below the policy minimum of %d", total, pp.MinSentinels)
+	}
+	if pc.Validated && reachable < pc.Quorum {
+		add(NOQUORUM, "only %d of %d sentinels are reachable, quorum %d can not be reached", reachable, total, pc.Quorum)
+	}
+	if pc.Quorum > total {
+		add(QUORUMTOOHIGH, "quorum %d is more than the %d sentinels, failover can never be agreed", pc.Quorum, total)
+	}
+	if pc.Quorum < majority(total) {
+		add(QUORUMBELOWMAJORITY, "quorum %d is less than the majority, %d, of %d sentinels, a minority can agree the master is down", pc.Quorum, majority(total), total)
+	}
+	if total%2 == 0 {
+		add(EVENSENTINELS, "%d sentinels is an even number, a partition can split them evenly leaving neither side a majority", total)
+	}
+	return
+}
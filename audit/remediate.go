@@ -0,0 +1,198 @@
+package audit
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/therealbill/libredis/client"
+)
+
+// RemediationAction is a single change to make to a running sentinel through
+// the SENTINEL command rather than by editing its config file.
+type RemediationAction struct {
+	Sentinel string
+	Pod      string
+	Command  string
+	Reason   string
+	apply    func(conn *client.Redis) error
+}
+
+func (a RemediationAction) String() string {
+	return fmt.Sprintf("SENTINEL %s %s on %s (%s)", a.Command, a.Pod, a.Sentinel, a.Reason)
+}
+
+// localSentinelAddress returns the address the local sentinel listens on.
+func (lsconf LocalSentinelConfig) localSentinelAddress() string {
+	if lsconf.Host == "" {
+		return fmt.Sprintf("127.0.0.1:%d", lsconf.Port)
+	}
+	return lsconf.Name
+}
+
+// monitorSettings returns the SENTINEL SET arguments, after the pod name,
+// which give a sentinel newly monitoring the pod the local config for it.
+// The auth-pass is left out, as it is set separately.
+func (pc *SentinelPodConfig) monitorSettings() (settings [][]string) {
+	set := func(args ...string) { settings = append(settings, args) }
+	set("down-after-milliseconds", strconv.Itoa(pc.DownAfterMilliseconds))
+	set("failover-timeout", strconv.Itoa(pc.FailoverTimeout))
+	set("parallel-syncs", strconv.Itoa(pc.ParallelSyncs))
+	if pc.AuthUser != "" {
+		set("auth-user", pc.AuthUser)
+	}
+	for _, command := range sortedKeys(pc.RenamedCommands) {
+		set("rename-command", command, pc.RenamedCommands[command])
+	}
+	if pc.NotificationScript != "" {
+		set("notification-script", pc.NotificationScript)
+	}
+	if pc.ReconfigScript != "" {
+		set("client-reconfig-script", pc.ReconfigScript)
+	}
+	return
+}
+
+// sentinelSet runs SENTINEL SET for the pod, returning the error the sentinel
+// replies with, such as for scripts when deny-scripts-reconfig is on.
+func sentinelSet(conn *client.Redis, pod string, args []string) error {
+	cmd := []interface{}{"SENTINEL", "SET", pod}
+	for _, arg := range args {
+		cmd = append(cmd, arg)
+	}
+	rp, err := conn.ExecuteCommand(cmd...)
+	if err != nil {
+		return err
+	}
+	if rp.Type == client.ErrorReply {
+		return fmt.Errorf("SENTINEL SET %s %s: %s", pod, args[0], rp.Error)
+	}
+	return nil
+}
+
+// globEscape escapes the characters SENTINEL RESET takes as a glob, so the
+// pattern matches only the pod named.
+func globEscape(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		if strings.ContainsRune(`*?[]\`, c) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// PlanRemediation works out the sentinel commands which fix the issues found
+// by the reports. On the local sentinel the unauthenticatable duplicate pod is
+// removed and pods with unreachable sentinels are reset so the stale entries
+// are purged; the pod name is escaped, as RESET takes a glob. Known sentinels
+// which do not monitor a pod are given the local config for it, its timing,
+// auth, renamed commands and scripts included, and those which lack its
+// auth-pass are given that.
+func (res *AuditResult) PlanRemediation() (plan []RemediationAction) {
+	local := res.Config.localSentinelAddress()
+	remove := res.podsToRemove()
+	var podnames []string
+	for name := range res.Config.ManagedPodConfigs {
+		podnames = append(podnames, name)
+	}
+	sort.Strings(podnames)
+
+	for _, name := range podnames {
+		pc := res.Config.ManagedPodConfigs[name]
+		if remove[name] {
+			plan = append(plan, RemediationAction{local, name, "REMOVE",
+				"can not authenticate to its duplicated master",
+				func(conn *client.Redis) error {
+					_, err := conn.SentinelRemove(pc.Name)
+					return err
+				}})
+			continue
+		}
+		if len(pc.InvalidSentinels) > 0 {
+			plan = append(plan, RemediationAction{local, name, "RESET",
+				fmt.Sprintf("%d unreachable sentinels", len(pc.InvalidSentinels)),
+				func(conn *client.Redis) error {
+					return conn.SentinelReset(globEscape(pc.Name))
+				}})
+		}
+		for _, d := range pc.Discrepancies {
+			switch {
+			case d.Issue == PODMISSINGONSENTINEL:
+				settings := pc.monitorSettings()
+				plan = append(plan, RemediationAction{d.Sentinel, name, "MONITOR",
+					fmt.Sprintf("not monitored, adding %s:%d quorum %d and %d settings", pc.IP, pc.Port, pc.Quorum, len(settings)),
+					func(conn *client.Redis) error {
+						if _, err := conn.SentinelMonitor(pc.Name, pc.IP, pc.Port, pc.Quorum); err != nil {
+							return err
+						}
+						for _, args := range settings {
+							if err := sentinelSet(conn, pc.Name, args); err != nil {
+								return err
+							}
+						}
+						if pc.AuthToken == "" {
+							return nil
+						}
+						return conn.SentinelSetPass(pc.Name, pc.AuthToken)
+					}})
+			case d.Issue == AUTHMISMATCH && pc.AuthToken != "":
+				plan = append(plan, RemediationAction{d.Sentinel, name, "SET auth-pass",
+					"not getting INFO from the master",
+					func(conn *client.Redis) error {
+						return conn.SentinelSetPass(pc.Name, pc.AuthToken)
+					}})
+			}
+		}
+	}
+	return
+}
+
+// Remediate writes the remediation plan to w and, when apply is set, runs
+// each action confirm agrees to, logging the outcome of every one of them.
+func (res *AuditResult) Remediate(w io.Writer, apply bool, confirm func(prompt string) bool) error {
+	plan := res.PlanRemediation()
+	if len(plan) == 0 {
+		fmt.Fprintln(w, "No remediation needed")
+		return nil
+	}
+	fmt.Fprintf(w, "Remediation plan (%d actions):\n", len(plan))
+	for i, action := range plan {
+		fmt.Fprintf(w, "%3d. %s\n", i+1, action)
+	}
+	if !apply {
+		return nil
+	}
+	fmt.Fprintln(w)
+	failed := 0
+	for _, action := range plan {
+		if !confirm(fmt.Sprintf("Apply %s?", action)) {
+			fmt.Fprintf(w, "%s SKIPPED %s\n", time.Now().Format(time.RFC3339), action)
+			continue
+		}
+		err := applyAction(action, res.prober)
+		if err != nil {
+			failed++
+			fmt.Fprintf(w, "%s FAILED %s: %s\n", time.Now().Format(time.RFC3339), action, err)
+			continue
+		}
+		fmt.Fprintf(w, "%s APPLIED %s\n", time.Now().Format(time.RFC3339), action)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d actions failed", failed, len(plan))
+	}
+	return nil
+}
+
+func applyAction(action RemediationAction, prober *Prober) error {
+	conn, err := prober.dialSentinel(action.Sentinel)
+	if err != nil {
+		return err
+	}
+	defer conn.ClosePool()
+	return action.apply(conn)
+}
@@ -0,0 +1,245 @@
+package audit
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// runAllReports runs every report against the loaded config.
+func (r *run) runAllReports() {
+	r.baseConfigReport()
+	r.knownSentinelsReport()
+	r.constellationReport()
+	r.findDupeMasterIPs()
+	r.findDupeSlaveIPs()
+	r.podReport()
+}
+
+// recordIssue notes that pod has the given issue, both in the per-issue
+// mapping used by the text reports and on the pod's managed config.
+func (r *run) recordIssue(issue ConfigIssue, pod SentinelPodConfig) {
+	r.podsWithIssues[issue] = append(r.podsWithIssues[issue], pod)
+	pc, exists := r.lsconf.ManagedPodConfigs[pod.Name]
+	if !exists {
+		return
+	}
+	for _, known := range pc.Issues {
+		if known == issue {
+			return
+		}
+	}
+	pc.Issues = append(pc.Issues, issue)
+	r.lsconf.ManagedPodConfigs[pod.Name] = pc
+}
+
+func (r *run) baseConfigReport() {
+	if r.lsconf.Name == "" {
+		fmt.Fprintln(r.out, "Bind Statement Present: False")
+	} else {
+		fmt.Fprintln(r.out, "Bind Statement Present: True")
+	}
+	if !r.opts.Policy.AllowsPort(r.lsconf.Port) {
+		fmt.Fprintf(r.out, "WARNING: Sentinel is running on a port not allowed by the policy: %d.", r.lsconf.Port)
+	}
+	if len(r.lsconf.Diagnostics) > 0 {
+		fmt.Fprintf(r.out, "\nConfig File Problems (%d):\n", len(r.lsconf.Diagnostics))
+		fmt.Fprintf(r.out, "=========================\n")
+		for _, d := range r.lsconf.Diagnostics {
+			fmt.Fprintln(r.out, d.Error())
+			if d.Line != "" {
+				fmt.Fprintf(r.out, "    %s\n", d.Line)
+			}
+		}
+	}
+	fmt.Fprintln(r.out)
+}
+
+func (r *run) knownSentinelsReport() {
+	fmt.Fprintf(r.out, "Known Sentinels (%d):\n", len(r.lsconf.KnownSentinels))
+	fmt.Fprintf(r.out, "=====================\n")
+	sentinels := sortedKeys(r.lsconf.KnownSentinels)
+	if r.opts.Offline {
+		for _, s := range sentinels {
+			fmt.Fprintf(r.out, "%s (SKIPPED - offline)\n", s)
+		}
+		fmt.Fprintln(r.out)
+		return
+	}
+	failed := r.prober.ProbeAll(sentinels)
+	for _, s := range sentinels {
+		if err, missing := failed[s]; missing && isAuthError(err) {
+			fmt.Fprintf(r.out, "%s (AUTH FAILED - err: '%s')\n", s, err)
+		} else if missing {
+			fmt.Fprintf(r.out, "%s (MISSING - err: '%s')\n", s, err)
+		} else {
+			fmt.Fprintf(r.out, "%s (Available)\n", s)
+		}
+	}
+	fmt.Fprintln(r.out)
+}
+
+func (r *run) podReport() {
+	fmt.Fprintf(r.out, "Locally Configured Pods: %d\n", len(r.lsconf.ManagedPodConfigs))
+	// Validate every pod concurrently; the prober bounds how many
+	// connections are open at once.
+	validated := make(map[string]SentinelPodConfig)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for k, v := range r.lsconf.ManagedPodConfigs {
+		wg.Add(1)
+		go func(k string, v SentinelPodConfig) {
+			defer wg.Done()
+			// The lookups take a slot like the connections do.
+			r.prober.Run(func() {
+				v.checkHostnames(r.addrs, r.lsconf.ResolveHostnames)
+				v.checkLocalAddresses(r.local, r.addrs)
+			})
+			if !r.opts.Offline {
+				v.validatePodSentinels(r.prober)
+				v.validatePodSlaves(r.prober, r.addrs)
+				r.prober.Run(func() { v.validateMaster(r.prober) })
+				v.Validated = true
+			}
+			mu.Lock()
+			validated[k] = v
+			mu.Unlock()
+		}(k, v)
+	}
+	wg.Wait()
+	for k, v := range validated {
+		r.lsconf.ManagedPodConfigs[k] = v
+	}
+	for k, v := range r.lsconf.ManagedPodConfigs {
+		pp := r.opts.Policy.ForPod(k)
+		issues := v.ConfigIssues(pp, r.lsconf.Name)
+		if len(issues) > 0 {
+			fmt.Fprintf(r.out, "%s has %d configuration issues\n", k, len(issues))
+			for _, issue := range issues {
+				r.recordIssue(issue, v)
+			}
+		}
+		switch {
+		case !v.Master.Checked:
+			// Offline nothing is known of the master.
+		case v.Master.Error != "":
+			fmt.Fprintf(r.out, "  master %s:%d is not valid: %s\n", v.IP, v.Port, v.Master.Error)
+		case v.Master.Role != "master":
+			fmt.Fprintf(r.out, "  master %s:%d reports role:%s\n", v.IP, v.Port, v.Master.Role)
+		case v.Master.ConnectedSlaves != len(v.Slaves):
+			fmt.Fprintf(r.out, "  master %s:%d has %d connected slaves, %d are known\n", v.IP, v.Port, v.Master.ConnectedSlaves, len(v.Slaves))
+		}
+		switch {
+		case v.Master.ACLError != "":
+			fmt.Fprintf(r.out, "  unable to verify ACL user %s on the master: %s\n", v.AuthUser, v.Master.ACLError)
+		case v.Master.ACLUser != "" && v.Master.ACLUser != v.AuthUser:
+			fmt.Fprintf(r.out, "  master authenticated auth-user %s as %s\n", v.AuthUser, v.Master.ACLUser)
+		case len(v.Master.MissingPermissions) > 0:
+			fmt.Fprintf(r.out, "  ACL user %s lacks %s on the master\n", v.AuthUser, strings.Join(v.Master.MissingPermissions, " "))
+		}
+		_, quorumReasons := v.quorumIssues(pp, r.lsconf.Name)
+		_, timingReasons := v.timingIssues(pp)
+		_, policyReasons := v.policyIssues(pp, r.lsconf.Name)
+		reasons := append(append(quorumReasons, timingReasons...), policyReasons...)
+		for _, reason := range reasons {
+			fmt.Fprintf(r.out, "  %s\n", reason)
+		}
+		for _, problem := range v.HostnameProblems {
+			fmt.Fprintf(r.out, "  %s\n", problem)
+		}
+		for _, problem := range v.LocalAddresses {
+			fmt.Fprintf(r.out, "  %s\n", problem)
+		}
+		for _, problem := range v.ColocatedNodes {
+			fmt.Fprintf(r.out, "  %s\n", problem)
+		}
+		for _, dupe := range v.DuplicateSentinels {
+			fmt.Fprintf(r.out, "  known sentinels %s are the same sentinel\n", dupe)
+		}
+		for slave, reason := range v.InvalidSlaves {
+			fmt.Fprintf(r.out, "  slave %s is not valid: %s\n", slave, reason)
+		}
+	}
+	issuecount := 0
+	for _, i := range r.podsWithIssues {
+		issuecount += len(i)
+	}
+	fmt.Fprintf(r.out, "%d of %d Pods have configuration issues", issuecount, len(r.lsconf.ManagedPodConfigs))
+	fmt.Fprintln(r.out)
+	if len(r.podsWithIssues) > 0 {
+		for issue, podlist := range r.podsWithIssues {
+			fmt.Fprintf(r.out, "\nConfig Issue: '%s'\n", issue)
+			fmt.Fprintf(r.out, "Pods with issue %d\n", len(podlist))
+			fmt.Fprintln(r.out, "=============================")
+			for _, pod := range podlist {
+				fmt.Fprintf(r.out, "  %s\n", pod.Name)
+			}
+
+		}
+	}
+}
+
+func (r *run) findDupeMasterIPs() {
+	r.masterIPtoPodMapping = make(map[string]SentinelPodConfig)
+	for _, v := range r.lsconf.ManagedPodConfigs {
+		// Masters named by hostname are compared by the address they
+		// resolve to. Pods on one host with different ports are distinct.
+		masterAddr := r.addrs.canonical(fmt.Sprintf("%s:%d", v.IP, v.Port))
+		opod, dupe := r.masterIPtoPodMapping[masterAddr]
+		if dupe {
+			fmt.Fprintf(r.out, "Found Duplicate master! %s and %s share master %s\n", opod.Name, v.Name, masterAddr)
+			r.recordIssue(DUPLICATEMASTERIP, v)
+			r.recordIssue(DUPLICATEMASTERIP, opod)
+			if r.opts.Offline {
+				continue
+			}
+
+			// test v
+			vconn, err := r.prober.dial(fmt.Sprintf("%s:%d", v.IP, v.Port), v.credentials())
+			if err != nil {
+				fmt.Fprintf(r.out, "Pod %s could not auth to %s, recommend deleting this one.\n", v.Name, v.IP)
+			} else {
+				vconn.ClosePool()
+				// test opod
+				oconn, err := r.prober.dial(fmt.Sprintf("%s:%d", opod.IP, opod.Port), opod.credentials())
+				if err != nil {
+					fmt.Fprintf(r.out, "Pod %s could not auth to %s, recommend deleting this one.\n", opod.Name, opod.IP)
+				} else {
+					oconn.ClosePool()
+				}
+			}
+
+		} else {
+			r.masterIPtoPodMapping[masterAddr] = v
+		}
+	}
+
+}
+
+func (r *run) findDupeSlaveIPs() {
+	slaveIPtoPodMapping := make(map[string]SentinelPodConfig)
+	masterAddrToPodMapping := make(map[string]SentinelPodConfig)
+	for _, v := range r.lsconf.ManagedPodConfigs {
+		masterAddrToPodMapping[r.addrs.canonical(fmt.Sprintf("%s:%d", v.IP, v.Port))] = v
+	}
+	for _, v := range r.lsconf.ManagedPodConfigs {
+		for _, configured := range v.Slaves {
+			slave := r.addrs.canonical(configured)
+			if opod, dupe := slaveIPtoPodMapping[slave]; dupe {
+				fmt.Fprintf(r.out, "Found Duplicate slave! %s and %s share slave IP %s\n", opod.Name, v.Name, slave)
+				r.recordIssue(DUPLICATESLAVEIP, v)
+				r.recordIssue(DUPLICATESLAVEIP, opod)
+			} else {
+				slaveIPtoPodMapping[slave] = v
+			}
+			if opod, dupe := masterAddrToPodMapping[slave]; dupe {
+				fmt.Fprintf(r.out, "Found Duplicate slave/master! %s is master for %s and slave for %s\n", slave, opod.Name, v.Name)
+				r.recordIssue(DUPLICATESLAVEIP, v)
+				r.recordIssue(DUPLICATESLAVEIP, opod)
+			} else {
+				slaveIPtoPodMapping[slave] = v
+			}
+		}
+	}
+
+}
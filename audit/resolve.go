@@ -0,0 +1,159 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver looks up the addresses of a hostname. *net.Resolver is one; tests
+// substitute their own.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// resolution is what looking up one hostname found.
+type resolution struct {
+	addrs []string
+	err   error
+}
+
+// addressBook resolves the hostnames a config names, each once, and gives
+// every host a canonical form, so a node named by hostname in one place and
+// by IP in another is recognized as the same node. Offline nothing is
+// resolved, and only the spelling of hosts is normalized.
+type addressBook struct {
+	resolver Resolver
+	timeout  time.Duration
+	offline  bool
+
+	mu    sync.Mutex
+	hosts map[string]resolution
+}
+
+func newAddressBook(resolver Resolver, timeout time.Duration, offline bool) *addressBook {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+	return &addressBook{
+		resolver: resolver,
+		timeout:  timeout,
+		offline:  offline,
+		hosts:    make(map[string]resolution),
+	}
+}
+
+// isHostname reports whether host is a name rather than an IP address.
+func isHostname(host string) bool {
+	return net.ParseIP(host) == nil
+}
+
+// resolve returns the addresses of host, sorted. An IP address resolves to
+// itself. Offline, hostnames resolve to nothing and no error.
+func (b *addressBook) resolve(host string) ([]string, error) {
+	if ip := net.ParseIP(host); ip != nil {
+		return []string{ip.String()}, nil
+	}
+	if b.offline {
+		return nil, nil
+	}
+	host = strings.ToLower(host)
+	b.mu.Lock()
+	res, known := b.hosts[host]
+	b.mu.Unlock()
+	if known {
+		return res.addrs, res.err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), b.timeout)
+	defer cancel()
+	found, err := b.resolver.LookupHost(ctx, host)
+	var addrs []string
+	for _, addr := range found {
+		if ip := net.ParseIP(addr); ip != nil {
+			addr = ip.String()
+		}
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	b.mu.Lock()
+	b.hosts[host] = resolution{addrs, err}
+	b.mu.Unlock()
+	return addrs, err
+}
+
+// canonicalHost returns the IP host resolves to when it resolves to exactly
+// one, and host in lower case otherwise.
+func (b *addressBook) canonicalHost(host string) string {
+	if addrs, err := b.resolve(host); err == nil && len(addrs) == 1 {
+		return addrs[0]
+	}
+	return strings.ToLower(host)
+}
+
+// canonical returns addr, a host:port as the config writes it, with its
+// host in canonical form.
+func (b *addressBook) canonical(addr string) string {
+	host, port := splitAddr(addr)
+	return net.JoinHostPort(b.canonicalHost(host), port)
+}
+
+// splitAddr splits a host:port as the config writes it. IPv6 hosts are not
+// bracketed, so the port follows the last colon.
+func splitAddr(addr string) (host, port string) {
+	if host, port, err := net.SplitHostPort(addr); err == nil {
+		return host, port
+	}
+	i := strings.LastIndex(addr, ":")
+	if i < 0 {
+		return addr, ""
+	}
+	return addr[:i], addr[i+1:]
+}
+
+// checkHostnames records what is wrong with the hostnames the pod names for
+// its master, slaves and sentinels: hostnames used without resolve-hostnames,
+// which Sentinel refuses, and hostnames which do not resolve or resolve to
+// several addresses, of which Sentinel uses whichever comes first. It also
+// records known sentinels listed under more than one address.
+func (pc *SentinelPodConfig) checkHostnames(b *addressBook, resolveHostnames bool) {
+	pc.HostnameProblems = nil
+	pc.DuplicateSentinels = nil
+	check := func(role, host string) {
+		if !isHostname(host) {
+			return
+		}
+		if !resolveHostnames {
+			pc.HostnameProblems = append(pc.HostnameProblems,
+				fmt.Sprintf("%s %s is a hostname but resolve-hostnames is off", role, host))
+		}
+		addrs, err := b.resolve(host)
+		switch {
+		case err != nil:
+			pc.HostnameProblems = append(pc.HostnameProblems,
+				fmt.Sprintf("%s %s does not resolve: %s", role, host, err))
+		case len(addrs) > 1:
+			pc.HostnameProblems = append(pc.HostnameProblems,
+				fmt.Sprintf("%s %s resolves to %d addresses: %s", role, host, len(addrs), strings.Join(addrs, ", ")))
+		}
+	}
+	check("master", pc.IP)
+	for _, slave := range pc.Slaves {
+		host, _ := splitAddr(slave)
+		check("slave", host)
+	}
+	seen := make(map[string]string)
+	for _, sentinel := range sortedKeys(pc.Sentinels) {
+		host, _ := splitAddr(sentinel)
+		check("sentinel", host)
+		canonical := b.canonical(sentinel)
+		if first, dupe := seen[canonical]; dupe {
+			pc.DuplicateSentinels = append(pc.DuplicateSentinels, fmt.Sprintf("%s and %s", first, sentinel))
+		} else {
+			seen[canonical] = sentinel
+		}
+	}
+}
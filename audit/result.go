@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+)
+
+// AuditResult is what an audit run found. It is also the machine readable
+// document emitted when -format=json is requested.
+type AuditResult struct {
+	Sentinel string
+	// Path names the config which was audited.
+	Path    string
+	RunAt   time.Time
+	Reports []string
+	Config  LocalSentinelConfig
+	Policy  Policy
+	// UnreachableSentinels are the sentinels which did not answer, with the
+	// reason for each.
+	UnreachableSentinels map[string]string
+	// AuthFailedSentinels are the sentinels which answered but refused the
+	// sentinel credentials, with the reason for each.
+	AuthFailedSentinels map[string]string
+	// Skipped lists the checks which were not made because the audit was
+	// run offline.
+	Skipped     []string
+	PodsByIssue map[ConfigIssue][]string
+	ExitStatus  int
+
+	// prober dialed for the run, and is reused with the same timeout,
+	// credentials and TLS settings when acting on the result.
+	prober *Prober
+	// addrs holds the hosts resolved for the run, so acting on the result
+	// compares addresses the way the reports did.
+	addrs *addressBook
+}
+
+// result assembles the AuditResult from the state left behind by the reports
+// which were run.
+func (r *run) result(path string, runAt time.Time) *AuditResult {
+	res := &AuditResult{
+		Sentinel:             r.lsconf.Name,
+		Path:                 path,
+		RunAt:                runAt,
+		Reports:              r.opts.Reports,
+		Config:               r.lsconf,
+		Policy:               r.opts.Policy,
+		UnreachableSentinels: r.prober.Unreachable(),
+		AuthFailedSentinels:  r.prober.AuthFailed(),
+		PodsByIssue:          make(map[ConfigIssue][]string),
+		prober:               r.prober,
+		addrs:                r.addrs,
+	}
+	if r.opts.Offline {
+		res.Skipped = networkChecks
+	}
+	for name, pod := range r.lsconf.ManagedPodConfigs {
+		for _, issue := range pod.Issues {
+			res.PodsByIssue[issue] = append(res.PodsByIssue[issue], name)
+		}
+	}
+	for _, pods := range res.PodsByIssue {
+		sort.Strings(pods)
+	}
+	res.ExitStatus = ExitStatus(res.Issues())
+	return res
+}
+
+// Issues returns every distinct issue found, in bit order.
+func (res *AuditResult) Issues() (issues []ConfigIssue) {
+	for issue := range res.PodsByIssue {
+		issues = append(issues, issue)
+	}
+	sort.Sort(byIssue(issues))
+	return
+}
+
+// addIssue records an issue found for pod after the audit ran, such as one
+// only seen by comparing configs.
+func (res *AuditResult) addIssue(pod string, issue ConfigIssue) {
+	pc := res.Config.ManagedPodConfigs[pod]
+	for _, known := range pc.Issues {
+		if known == issue {
+			return
+		}
+	}
+	pc.Issues = append(pc.Issues, issue)
+	res.Config.ManagedPodConfigs[pod] = pc
+	res.PodsByIssue[issue] = append(res.PodsByIssue[issue], pod)
+	sort.Strings(res.PodsByIssue[issue])
+	res.ExitStatus = ExitStatus(res.Issues())
+}
+
+// isUnreachable reports whether the sentinel at addr did not answer.
+func (res *AuditResult) isUnreachable(addr string) bool {
+	_, unreachable := res.UnreachableSentinels[addr]
+	return unreachable
+}
+
+// WriteJSON writes the result to w as an indented JSON document.
+func (res *AuditResult) WriteJSON(w io.Writer) error {
+	enc, err := json.MarshalIndent(res, "", "  ")
+	if err != nil {
+		return err
+	}
+	enc = append(enc, '\n')
+	_, err = w.Write(enc)
+	return err
+}
@@ -0,0 +1,58 @@
+package audit
+
+import "fmt"
+
+// The values sentinel uses when a pod's config does not set them.
+const (
+	defaultDownAfter       = 30000
+	defaultFailoverTimeout = 180000
+	defaultParallelSyncs   = 1
+)
+
+// estimatedResyncMilliseconds is how long a full resync of the pod's master
+// can be expected to take at bytesPerSecond, based on the memory it reported.
+// It is 0 when the master has not been checked or no rate is given.
+func (pc *SentinelPodConfig) estimatedResyncMilliseconds(bytesPerSecond int) int {
+	if bytesPerSecond <= 0 {
+		return 0
+	}
+	return int(int64(pc.Master.UsedMemory) * 1000 / int64(bytesPerSecond))
+}
+
+// timingIssues checks the pod's down-after-milliseconds, failover-timeout and
+// parallel-syncs against its policy, returning the issues found and an
+// explanation of each.
+func (pc *SentinelPodConfig) timingIssues(pp PodPolicy) (issues []ConfigIssue, reasons []string) {
+	add := func(issue ConfigIssue, format string, args ...interface{}) {
+		if len(issues) == 0 || issues[len(issues)-1] != issue {
+			issues = append(issues, issue)
+		}
+		reasons = append(reasons, fmt.Sprintf(format, args...))
+	}
+	if pc.DownAfterMilliseconds < pp.MinDownAfter {
+		add(DOWNAFTERTOOLOW, "down-after-milliseconds %d is below %d", pc.DownAfterMilliseconds, pp.MinDownAfter)
+	}
+	if pp.MaxDownAfter > 0 && pc.DownAfterMilliseconds > pp.MaxDownAfter {
+		add(DOWNAFTERTOOHIGH, "down-after-milliseconds %d is above %d", pc.DownAfterMilliseconds, pp.MaxDownAfter)
+	}
+	if resync := pc.estimatedResyncMilliseconds(pp.ResyncBytesPerSecond); resync > pp.MinFailoverTimeout {
+		if pc.FailoverTimeout < resync {
+			add(FAILOVERTIMEOUTTOOSHORT, "failover-timeout %d is below %d, the time a full resync of %d bytes may take", pc.FailoverTimeout, resync, pc.Master.UsedMemory)
+		}
+	} else if pc.FailoverTimeout < pp.MinFailoverTimeout {
+		add(FAILOVERTIMEOUTTOOSHORT, "failover-timeout %d is below the minimum of %d", pc.FailoverTimeout, pp.MinFailoverTimeout)
+	}
+	if len(pc.Slaves) > 1 && pc.ParallelSyncs >= len(pc.Slaves) {
+		add(PARALLELSYNCSALLSLAVES, "parallel-syncs %d lets all %d slaves resync at once", pc.ParallelSyncs, len(pc.Slaves))
+	}
+	if pp.DownAfter > 0 && pc.DownAfterMilliseconds != pp.DownAfter {
+		add(TIMINGPOLICYMISMATCH, "down-after-milliseconds %d differs from the policy of %d", pc.DownAfterMilliseconds, pp.DownAfter)
+	}
+	if pp.FailoverTimeout > 0 && pc.FailoverTimeout != pp.FailoverTimeout {
+		add(TIMINGPOLICYMISMATCH, "failover-timeout %d differs from the policy of %d", pc.FailoverTimeout, pp.FailoverTimeout)
+	}
+	if pp.ParallelSyncs > 0 && pc.ParallelSyncs != pp.ParallelSyncs {
+		add(TIMINGPOLICYMISMATCH, "parallel-syncs %d differs from the policy of %d", pc.ParallelSyncs, pp.ParallelSyncs)
+	}
+	return
+}
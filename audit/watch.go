@@ -0,0 +1,268 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"time"
+)
+
+// Delta is what changed between two audits of the same config.
+type Delta struct {
+	Path  string
+	RunAt time.Time
+	// Error is why the config could not be audited. The next audit which
+	// succeeds is compared with the last one which did.
+	Error       string `json:",omitempty"`
+	PodsAdded   []string
+	PodsRemoved []string
+	// NewIssues and ResolvedIssues map each pod to the issues it gained and
+	// lost. The issues of added pods are new; those of removed pods are not
+	// listed as resolved.
+	NewIssues      map[string][]ConfigIssue
+	ResolvedIssues map[string][]ConfigIssue
+}
+
+// Diff returns what changed from prev to cur. A nil prev is an audit which
+// found no pods, so every pod and issue of cur is new.
+func Diff(prev, cur *AuditResult) *Delta {
+	d := &Delta{
+		Path:           cur.Path,
+		RunAt:          cur.RunAt,
+		NewIssues:      make(map[string][]ConfigIssue),
+		ResolvedIssues: make(map[string][]ConfigIssue),
+	}
+	before, after := podIssues(prev), podIssues(cur)
+	for pod, issues := range after {
+		was, existed := before[pod]
+		if !existed {
+			d.PodsAdded = append(d.PodsAdded, pod)
+		}
+		if gained := issueDifference(issues, was); len(gained) > 0 {
+			d.NewIssues[pod] = gained
+		}
+		if existed {
+			if lost := issueDifference(was, issues); len(lost) > 0 {
+				d.ResolvedIssues[pod] = lost
+			}
+		}
+	}
+	for pod := range before {
+		if _, exists := after[pod]; !exists {
+			d.PodsRemoved = append(d.PodsRemoved, pod)
+		}
+	}
+	sort.Strings(d.PodsAdded)
+	sort.Strings(d.PodsRemoved)
+	return d
+}
+
+// podIssues maps every pod of the result to the set of issues found for it.
+func podIssues(res *AuditResult) map[string]map[ConfigIssue]bool {
+	pods := make(map[string]map[ConfigIssue]bool)
+	if res == nil {
+		return pods
+	}
+	for name := range res.Config.ManagedPodConfigs {
+		pods[name] = make(map[ConfigIssue]bool)
+	}
+	for issue, names := range res.PodsByIssue {
+		for _, name := range names {
+			if pods[name] != nil {
+				pods[name][issue] = true
+			}
+		}
+	}
+	return pods
+}
+
+// issueDifference returns the issues in a but not in b, in bit order.
+func issueDifference(a, b map[ConfigIssue]bool) (issues []ConfigIssue) {
+	for issue := range a {
+		if !b[issue] {
+			issues = append(issues, issue)
+		}
+	}
+	sort.Sort(byIssue(issues))
+	return
+}
+
+// Empty reports whether nothing changed.
+func (d *Delta) Empty() bool {
+	return d.Error == "" && len(d.PodsAdded) == 0 && len(d.PodsRemoved) == 0 &&
+		len(d.NewIssues) == 0 && len(d.ResolvedIssues) == 0
+}
+
+// Write writes the delta as text: a line per pod added (+) or removed (-)
+// and per issue introduced (+) or resolved (-).
+func (d *Delta) Write(w io.Writer) {
+	fmt.Fprintf(w, "%s audit of %s\n", d.RunAt.Format(time.RFC3339), d.Path)
+	if d.Error != "" {
+		fmt.Fprintf(w, "  unable to audit: %s\n", d.Error)
+		return
+	}
+	if d.Empty() {
+		fmt.Fprintln(w, "  no pods or issues changed")
+		return
+	}
+	for _, pod := range d.PodsAdded {
+		fmt.Fprintf(w, "  + pod %s\n", pod)
+	}
+	for _, pod := range d.PodsRemoved {
+		fmt.Fprintf(w, "  - pod %s\n", pod)
+	}
+	for _, pod := range sortedIssueKeys(d.NewIssues) {
+		for _, issue := range d.NewIssues[pod] {
+			fmt.Fprintf(w, "  + %s: %s (%s)\n", pod, issue.Name(), issue)
+		}
+	}
+	for _, pod := range sortedIssueKeys(d.ResolvedIssues) {
+		for _, issue := range d.ResolvedIssues[pod] {
+			fmt.Fprintf(w, "  - %s: %s (%s)\n", pod, issue.Name(), issue)
+		}
+	}
+}
+
+func sortedIssueKeys(m map[string][]ConfigIssue) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// WriteJSON writes the delta to w as a single line of JSON.
+func (d *Delta) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(d)
+}
+
+// watchSettle is how long a change to the config must be followed by quiet
+// before it is audited. Sentinel rewrites its config in several writes.
+const watchSettle = 200 * time.Millisecond
+
+// Watch audits the config, then audits it again whenever the file changes,
+// passing report what changed each time; the first delta holds every pod
+// and issue. Changes are noticed with inotify where it is available, and
+// otherwise by checking the file every poll. Watch returns when stop is
+// closed, or if the file can not be watched.
+func (a *Auditor) Watch(poll time.Duration, stop <-chan struct{}, report func(*Delta)) error {
+	path := a.Source.Path()
+	watcher, err := newFileWatcher(path, poll)
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+	quiet := *a
+	quiet.Options.Output = nil
+	var prev *AuditResult
+	audit := func() {
+		res, err := quiet.Run()
+		if err != nil {
+			report(&Delta{Path: path, RunAt: time.Now(), Error: err.Error()})
+			return
+		}
+		report(Diff(prev, res))
+		prev = res
+	}
+	audit()
+	for {
+		select {
+		case <-stop:
+			return nil
+		case _, ok := <-watcher.Changes():
+			if !ok {
+				return fmt.Errorf("stopped watching %s", path)
+			}
+		}
+		// Let the writes settle, folding them into one audit.
+		settle := time.After(watchSettle)
+	settling:
+		for {
+			select {
+			case <-stop:
+				return nil
+			case _, ok := <-watcher.Changes():
+				if !ok {
+					return fmt.Errorf("stopped watching %s", path)
+				}
+			case <-settle:
+				break settling
+			}
+		}
+		audit()
+	}
+}
+
+// fileWatcher signals changes to a file on its Changes channel, which is
+// closed if watching fails.
+type fileWatcher interface {
+	Changes() <-chan struct{}
+	Close() error
+}
+
+// newFileWatcher watches path with inotify, falling back to polling every
+// poll where inotify is not available.
+func newFileWatcher(path string, poll time.Duration) (fileWatcher, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+	watcher, err := newInotifyWatcher(path)
+	if err == nil {
+		return watcher, nil
+	}
+	log.Printf("Polling %s every %s: %s", path, poll, err)
+	return newPollWatcher(path, poll), nil
+}
+
+// pollWatcher notices changes to a file by checking its size, modification
+// time and inode, so a file replaced by rename is noticed too.
+type pollWatcher struct {
+	changes chan struct{}
+	done    chan struct{}
+}
+
+func newPollWatcher(path string, poll time.Duration) *pollWatcher {
+	p := &pollWatcher{changes: make(chan struct{}, 1), done: make(chan struct{})}
+	last, lastErr := os.Stat(path)
+	go func() {
+		ticker := time.NewTicker(poll)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-ticker.C:
+			}
+			info, err := os.Stat(path)
+			changed := (err == nil) != (lastErr == nil)
+			if err == nil && lastErr == nil {
+				changed = info.Size() != last.Size() || !info.ModTime().Equal(last.ModTime()) || !os.SameFile(info, last)
+			}
+			last, lastErr = info, err
+			if changed {
+				signal(p.changes)
+			}
+		}
+	}()
+	return p
+}
+
+func (p *pollWatcher) Changes() <-chan struct{} { return p.changes }
+
+func (p *pollWatcher) Close() error {
+	close(p.done)
+	return nil
+}
+
+// signal notes a change without blocking; one pending change stands for any
+// number.
+func signal(changes chan struct{}) {
+	select {
+	case changes <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyWatcher watches the directory holding the file rather than the
+// file itself, so the file is still watched after being replaced by rename,
+// as Sentinel rewrites its config.
+type inotifyWatcher struct {
+	f       *os.File
+	changes chan struct{}
+}
+
+func newInotifyWatcher(path string) (fileWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	const mask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_CREATE |
+		syscall.IN_DELETE | syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), mask); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+	// A non-blocking descriptor is read through the runtime poller, so
+	// Close interrupts a pending Read.
+	w := &inotifyWatcher{f: os.NewFile(uintptr(fd), "inotify"), changes: make(chan struct{}, 1)}
+	go w.read(filepath.Base(path))
+	return w, nil
+}
+
+// read signals a change for every event naming the file, until the watcher
+// is closed.
+func (w *inotifyWatcher) read(name string) {
+	defer close(w.changes)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			ev := (*syscall.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			start := off + syscall.SizeofInotifyEvent
+			end := start + int(ev.Len)
+			if end > n {
+				break
+			}
+			if string(bytes.TrimRight(buf[start:end], "\x00")) == name {
+				signal(w.changes)
+			}
+			off = end
+		}
+	}
+}
+
+func (w *inotifyWatcher) Changes() <-chan struct{} { return w.changes }
+
+func (w *inotifyWatcher) Close() error {
+	return w.f.Close()
+}
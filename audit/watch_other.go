@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package audit
+
+import "errors"
+
+func newInotifyWatcher(path string) (fileWatcher, error) {
+	return nil, errors.New("inotify is only available on Linux")
+}
@@ -4,452 +4,275 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
-	"io"
 	"log"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/therealbill/audit-sentinel-config/audit"
 	"github.com/therealbill/libredis/client"
-	"github.com/therealbill/libredis/info"
-
-	"strconv"
-	"strings"
-)
-
-type ConfigIssue int
-
-const (
-	NOTENOUGHSENTINELS ConfigIssue = 1 << iota
-	NOQUORUM
-	NOSLAVES
-	NOVALIDSLAVES
-	HASINVALIDSENTINELS
-	DUPLICATEMASTERIP
-	DUPLICATESLAVEIP
 )
 
-func (ci ConfigIssue) String() string {
-	s := ""
-	switch ci {
-	case NOTENOUGHSENTINELS:
-		s += "Not Enough Sentinels"
-	case NOQUORUM:
-		s += "NO Quorum Possible"
-	case NOSLAVES:
-		s += "No Slaves Configured/Known"
-	case NOVALIDSLAVES:
-		s += "No Valid Slaves"
-	case HASINVALIDSENTINELS:
-		s += "Has Sentinels Configured which do not exist or are unreachable"
-	case DUPLICATEMASTERIP:
-		s += "Shares a master IP with another pod."
-	case DUPLICATESLAVEIP:
-		s += "Shares a slave IP with another pod."
-	}
-	return s
-}
-
-var (
-	lsconf               LocalSentinelConfig
-	PodsWithIssues       map[ConfigIssue][]SentinelPodConfig
-	masterIPtoPodMapping map[string]SentinelPodConfig
-)
+type Report []string
 
-type NodeInfo struct {
-	Name      string
-	Info      info.RedisInfoAll
-	AuthToken string
+func (r *Report) String() string {
+	return fmt.Sprint(*r)
 }
 
-func (n *NodeInfo) MaxMemory() (int64, error) {
-	var config client.DialConfig
-	config.Address = n.Name
-	config.Password = n.AuthToken
-	var maxmem int64
-	conn, err := client.DialWithConfig(&config)
-	if err != nil {
-		log.Printf("Error on dial: Err='%s'", err)
-		return maxmem, err
-	}
-	res, err := conn.ConfigGet("maxmemory")
-	if err != nil {
-		return maxmem, err
+func (r *Report) Set(value string) error {
+	for _, rpt := range strings.Split(value, ",") {
+		*r = append(*r, rpt)
 	}
-	maxmem, err = strconv.ParseInt(res["maxmemory"], 10, 64)
-	return maxmem, nil
-
+	return nil
 }
 
-type SentinelPodConfig struct {
-	IP                 string
-	Port               int
-	Quorum             int
-	Name               string
-	AuthToken          string
-	Sentinels          map[string]string
-	Slaves             []string
-	ConfirmedSentinels map[string]string
-	InvalidSentinels   map[string]string
-}
+// ConfigList collects the configs to audit from repeated or comma-separated
+// -config flags.
+type ConfigList []string
 
-type LocalSentinelConfig struct {
-	Name               string
-	Host               string
-	Port               int
-	ManagedPodConfigs  map[string]SentinelPodConfig
-	Dir                string
-	KnownSentinels     map[string]string
-	InvalidSentinels   map[string]string
-	ConfigIssueMapping map[ConfigIssue][]SentinelPodConfig
+func (c *ConfigList) String() string {
+	return strings.Join(*c, ",")
 }
 
-func LoadSentinelConfigFile() error {
-	lsconf.ManagedPodConfigs = make(map[string]SentinelPodConfig)
-	lsconf.KnownSentinels = make(map[string]string)
-	file, err := os.Open(useConfig)
-	defer file.Close()
-	if err != nil {
-		log.Print(err)
-		return err
+func (c *ConfigList) Set(value string) error {
+	for _, path := range strings.Split(value, ",") {
+		*c = append(*c, path)
 	}
-	bf := bufio.NewReader(file)
-	for {
-		rawline, err := bf.ReadString('\n')
-		if err == nil || err == io.EOF {
-			line := strings.TrimSpace(rawline)
-			// ignore comments
-			if strings.Contains(line, "#") {
-				continue
-			}
-			entries := strings.Split(line, " ")
-			//Most values are key/value pairs
-			switch entries[0] {
-			case "sentinel": // Have a sentinel directive
-				err := extractSentinelDirective(entries[1:])
-				if err != nil {
-					// TODO: Fix this to return a different error if we can't
-					// connect to the sentinel
-					log.Printf("Misshapen sentinel directive: '%s'", line, err)
-				}
-			case "port":
-				iport, _ := strconv.Atoi(entries[1])
-				lsconf.Port = iport
-				if lsconf.Host > "" {
-					lsconf.Name = fmt.Sprintf("%s:%d", lsconf.Host, lsconf.Port)
-				}
-			case "dir":
-				lsconf.Dir = entries[1]
-			case "bind":
-				lsconf.Host = entries[1]
-				log.Printf("Local sentinel is listening on IP %s", lsconf.Host)
-				if lsconf.Port > 0 {
-					lsconf.Name = fmt.Sprintf("%s:%d", lsconf.Host, lsconf.Port)
-				}
-			case "":
-				if err == io.EOF {
-					log.Print("File load complete?")
-					return nil
-				}
-			// ignore these
-			case "maxclients":
-			default:
-				log.Printf("Unhandled Config Directive: %s", line)
-			}
-		} else {
-			log.Print("=============== LOAD FILE ERROR ===============")
-			log.Fatal(err)
-		}
-	}
-	lsconf.Name = fmt.Sprintf("%s:%d", lsconf.Host, lsconf.Port)
 	return nil
 }
 
-func extractSentinelDirective(entries []string) error {
-	switch entries[0] {
-	case "monitor":
-		pname := entries[1]
-		port, _ := strconv.Atoi(entries[3])
-		quorum, _ := strconv.Atoi(entries[4])
-		spc := SentinelPodConfig{Name: pname, IP: entries[2], Port: port, Quorum: quorum}
-		spc.Sentinels = make(map[string]string)
-		// normally we should not see duplicate IP:PORT combos, so detect them
-		// and ignore the second one if found, reporting the error condition
-		// this will require tracking ip:port pairs...
-		addr := fmt.Sprintf("%s:%d", entries[2], port)
-		_, exists := lsconf.ManagedPodConfigs[addr]
-		if !exists {
-			lsconf.ManagedPodConfigs[entries[1]] = spc
-		}
-		return nil
+const defaultConfig = "/etc/redis/sentinel.conf"
 
-	case "auth-pass":
-		pname := entries[1]
-		pc := lsconf.ManagedPodConfigs[pname]
-		pc.AuthToken = entries[2]
-		lsconf.ManagedPodConfigs[pname] = pc
-		return nil
+// watchPoll is how often -watch checks the config where inotify is not
+// available.
+const watchPoll = 2 * time.Second
 
-	case "known-sentinel":
-		podname := entries[1]
-		sentinel_address := entries[2] + ":" + entries[3]
-		pc := lsconf.ManagedPodConfigs[podname]
-		pc.Sentinels[sentinel_address] = ""
-		isMe := sentinel_address == lsconf.Name
-		if !isMe {
-			lsconf.KnownSentinels[sentinel_address] = sentinel_address
-		}
-		return nil
+var reportFlag Report
+var showByError bool
+var configFlag ConfigList
+var outputFormat string
+var nagiosMode bool
+var listenAddr string
+var auditInterval time.Duration
+var watchMode bool
+var fixMode bool
+var dryRun bool
+var assumeYes bool
+var remediateMode bool
+var applyRemediation bool
+var policyFile string
+var probeParallelism int
+var probeTimeout time.Duration
+var offlineMode bool
+var sentinelUser string
+var sentinelPass string
+var useTLS bool
+var tlsOpts client.TLSOptions
 
-	case "known-slave":
-		// Currently ignoring this, but may add call to a node manager.
-		podname := entries[1]
-		slave := entries[2] + ":" + entries[3]
-		pc := lsconf.ManagedPodConfigs[podname]
-		pc.Slaves = append(pc.Slaves, slave)
-		lsconf.ManagedPodConfigs[podname] = pc
-		return nil
+func init() {
+	flag.Var(&reportFlag, "report", "comma-separated list of reports to run")
+	flag.BoolVar(&showByError, "byerror", true, "group errors by error type")
+	flag.Var(&configFlag, "config", "configs to audit: files, globs or directories of *.conf files, comma-separated or repeated (default "+defaultConfig+")")
+	flag.StringVar(&outputFormat, "format", "text", "output format: text or json")
+	flag.BoolVar(&nagiosMode, "nagios", false, "run all reports as a Nagios check plugin")
+	flag.StringVar(&listenAddr, "listen", "", "run continuously, serving Prometheus metrics on this address (e.g. :9479)")
+	flag.DurationVar(&auditInterval, "interval", time.Minute, "how often to re-run the audit in -listen mode")
+	flag.BoolVar(&watchMode, "watch", false, "run continuously, re-auditing when the config changes and printing only what changed")
+	flag.BoolVar(&fixMode, "fix", false, "run all reports, then offer to remove the config lines they show to be wrong")
+	flag.BoolVar(&dryRun, "dry-run", false, "with -fix, only show the changes as a diff")
+	flag.BoolVar(&assumeYes, "yes", false, "with -fix or -remediate -apply, make the changes without asking")
+	flag.BoolVar(&remediateMode, "remediate", false, "run all reports, then plan SENTINEL commands which fix the issues found")
+	flag.BoolVar(&applyRemediation, "apply", false, "with -remediate, run the planned commands")
+	flag.StringVar(&policyFile, "policy", "", "JSON policy file declaring the thresholds the checks use")
+	flag.IntVar(&probeParallelism, "parallel", 16, "how many sentinels and nodes to probe at once")
+	flag.DurationVar(&probeTimeout, "probe-timeout", 2*time.Second, "how long to wait when connecting to a sentinel or node, and for each reply")
+	flag.BoolVar(&offlineMode, "offline", false, "only make the checks which need no network access")
+	flag.StringVar(&sentinelUser, "sentinel-user", "", "ACL user to authenticate to sentinels as (or $SENTINEL_USER)")
+	flag.BoolVar(&useTLS, "tls", false, "connect to sentinels and nodes over TLS (default from the config's tls-replication)")
+	flag.StringVar(&tlsOpts.CACertFile, "tls-ca-cert", "", "PEM CA bundle to verify servers with (default from tls-ca-cert-file)")
+	flag.StringVar(&tlsOpts.CertFile, "tls-cert", "", "PEM client certificate (default from tls-client-cert-file or tls-cert-file)")
+	flag.StringVar(&tlsOpts.KeyFile, "tls-key", "", "PEM client key (default from tls-client-key-file or tls-key-file)")
+	flag.StringVar(&tlsOpts.ServerName, "tls-server-name", "", "name to verify server certificates against instead of the address dialed")
+	flag.BoolVar(&tlsOpts.InsecureSkipVerify, "tls-insecure-skip-verify", false, "accept any server certificate")
+	flag.StringVar(&sentinelPass, "sentinel-pass", "", "password to authenticate to sentinels with (or $SENTINEL_PASS); defaults to the config's sentinel-pass")
+}
 
-	case "config-epoch", "leader-epoch", "current-epoch", "down-after-milliseconds", "maxclients":
-		// We don't use these keys
-		return nil
+// stdin is shared by every confirmation prompt so that answers piped in are
+// not lost to a discarded buffer.
+var stdin = bufio.NewReader(os.Stdin)
 
-	default:
-		err := fmt.Errorf("Unhandled sentinel directive: '%+v'", entries)
-		log.Print(err)
-		return nil
+// confirm asks the operator whether to go ahead, unless -yes was given.
+func confirm(prompt string) bool {
+	if assumeYes {
+		return true
 	}
-	return nil
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
-func sentinelAvailable(name string) (bool, error) {
-	if lsconf.InvalidSentinels == nil {
-		lsconf.InvalidSentinels = make(map[string]string)
-	}
-	_, knownInvalid := lsconf.InvalidSentinels[name]
-	if knownInvalid {
-		return false, fmt.Errorf("Known Invalid Sentinel")
+// abort reports that the audit could not be completed and exits with the
+// status appropriate to the mode we are running in.
+func abort(msg string, err error) {
+	if nagiosMode {
+		fmt.Printf("SENTINEL CONFIG UNKNOWN - %s: %s\n", msg, err)
+		os.Exit(int(audit.UNKNOWN))
 	}
-	conn, err := client.DialWithConfig(&client.DialConfig{Address: name, Timeout: 2 * time.Second})
-	if err != nil {
-		lsconf.InvalidSentinels[name] = ""
-		return false, err
-	}
-	err = conn.Ping()
-	return true, err
+	log.Printf("%s: %s", msg, err)
+	os.Exit(audit.ExitAuditFailure)
 }
 
-func (pc *SentinelPodConfig) ConfigIssues() (issues []ConfigIssue) {
-	if len(pc.InvalidSentinels) > 0 {
-		issues = append(issues, HASINVALIDSENTINELS)
+func main() {
+	flag.Parse()
+	opts := audit.Options{
+		Reports:     reportFlag,
+		Policy:      audit.DefaultPolicy(),
+		Parallelism: probeParallelism,
+		Timeout:     probeTimeout,
+		Output:      os.Stdout,
+		Offline:     offlineMode,
 	}
-	if len(pc.ConfirmedSentinels) < pc.Quorum {
-		issues = append(issues, NOQUORUM)
+	// The environment keeps the password out of the process list.
+	if sentinelUser == "" {
+		sentinelUser = os.Getenv("SENTINEL_USER")
 	}
-	if len(pc.ConfirmedSentinels) < pc.Quorum {
-		issues = append(issues, NOTENOUGHSENTINELS)
+	if sentinelPass == "" {
+		sentinelPass = os.Getenv("SENTINEL_PASS")
 	}
-	//log.Printf("Config: %+v", pc)
-	/// TODO: Add Slave Tests
-
-	// set errorcode
-	return
-}
-
-func (pc *SentinelPodConfig) validatePodSentinels() {
-	if pc.ConfirmedSentinels == nil {
-		pc.ConfirmedSentinels = make(map[string]string)
+	opts.SentinelAuth = audit.Credentials{User: sentinelUser, Pass: sentinelPass}
+	if useTLS || tlsOpts != (client.TLSOptions{}) {
+		opts.TLS = &tlsOpts
 	}
-	if pc.InvalidSentinels == nil {
-		pc.InvalidSentinels = make(map[string]string)
+	if watchMode && (listenAddr != "" || nagiosMode || fixMode || remediateMode) {
+		abort("invalid -watch", fmt.Errorf("-watch can not be combined with -listen, -nagios, -fix or -remediate"))
 	}
-	for sentinel, _ := range pc.Sentinels {
-		_, knownInvalid := lsconf.InvalidSentinels[sentinel]
-		if knownInvalid {
-			pc.InvalidSentinels[sentinel] = ""
-		}
-		isValid, _ := sentinelAvailable(sentinel)
-		if isValid {
-			pc.ConfirmedSentinels[sentinel] = ""
-		} else {
-			pc.InvalidSentinels[sentinel] = ""
-			//log.Printf("%s err:%s", sentinel, err)
+	if offlineMode && remediateMode {
+		abort("invalid -offline", fmt.Errorf("-remediate sends commands to the sentinels and can not run offline"))
+	}
+	switch outputFormat {
+	case "text":
+	case "json":
+		opts.Output = nil
+	default:
+		abort("invalid -format", fmt.Errorf("unknown output format '%s'", outputFormat))
+	}
+	if policyFile != "" {
+		var err error
+		opts.Policy, err = audit.LoadPolicy(policyFile)
+		if err != nil {
+			abort("unable to load policy file", err)
 		}
 	}
-}
-
-type Report []string
-
-func (r *Report) String() string {
-	return fmt.Sprint(*r)
-}
-
-func (r *Report) Set(value string) error {
-	for _, rpt := range strings.Split(value, ",") {
-		*r = append(*r, rpt)
+	if nagiosMode || fixMode || remediateMode || watchMode || listenAddr != "" {
+		opts.Output = nil
+		opts.Reports = []string{"all"}
 	}
-	return nil
-}
-
-var reportFlag Report
-var showByError bool
-var useConfig string
-
-func init() {
-	flag.Var(&reportFlag, "report", "comma-separated list of reports to run")
-	flag.BoolVar(&showByError, "byerror", true, "group errors by error type")
-	flag.StringVar(&useConfig, "config", "/etc/redis/sentinel.conf", "If your config is not /etc/redis/sentinel.conf, specify it here")
-	PodsWithIssues = make(map[ConfigIssue][]SentinelPodConfig)
-}
-
-func BaseConfigReport() {
-	if lsconf.Name == "" {
-		log.Print("WARNING: MISSING BIND DIRECTIVE!")
-		fmt.Println("Bind Statement Present: False")
-	} else {
-		fmt.Println("Bind Statement Present: True")
+	// Configs may also follow the flags, as a shell expands -config *.conf.
+	patterns := append(configFlag, flag.Args()...)
+	if len(patterns) == 0 {
+		patterns = ConfigList{defaultConfig}
 	}
-	if lsconf.Port != 26379 {
-		fmt.Printf("WARNING: Sentinel is running on non-standard port: %d.", lsconf.Port)
+	paths, err := audit.ExpandConfigPaths(patterns)
+	if err != nil {
+		abort("unable to find configs", err)
 	}
-
-}
-
-func KnownSentinelsReport() {
-	fmt.Printf("Known Sentinels (%d):\n", len(lsconf.KnownSentinels))
-	fmt.Printf("=====================\n")
-	for s, _ := range lsconf.KnownSentinels {
-		isAvailable, err := sentinelAvailable(s)
-		if isAvailable {
-			fmt.Printf("%s (Available)\n", s)
-		} else {
-			fmt.Printf("%s (MISSING - err: '%s')\n", s, err)
+	if len(paths) > 1 {
+		if listenAddr != "" {
+			abort("invalid -listen", fmt.Errorf("-listen audits a single config, %d given", len(paths)))
 		}
-	}
-	fmt.Println()
-}
-
-func PodReport() {
-	fmt.Printf("Locally Configured Pods: %d\n", len(lsconf.ManagedPodConfigs))
-	for k, v := range lsconf.ManagedPodConfigs {
-		//log.Printf("%s: %+v", k, v)
-		v.validatePodSentinels()
-		issues := v.ConfigIssues()
-		if len(issues) > 0 {
-			fmt.Printf("%s has %d configuration issues", k, len(issues))
-			for _, issue := range issues {
-				lsconf.ConfigIssueMapping[issue] = append(lsconf.ConfigIssueMapping[issue], v)
-				PodsWithIssues[issue] = append(PodsWithIssues[issue], v)
-			}
+		if watchMode {
+			abort("invalid -watch", fmt.Errorf("-watch watches a single config, %d given", len(paths)))
 		}
+		os.Exit(auditConfigs(paths, opts))
 	}
-	issuecount := 0
-	for _, i := range PodsWithIssues {
-		issuecount += len(i)
+	useConfig := paths[0]
+	auditor := audit.NewAuditor(audit.FileSource(useConfig), opts)
+	if listenAddr != "" {
+		auditor.Options.Output = nil
+		abort("metrics exporter stopped", auditor.ServeMetrics(listenAddr, auditInterval))
 	}
-	fmt.Printf("%d of %d Pods have configuration issues", issuecount, len(lsconf.ManagedPodConfigs))
-	fmt.Println()
-	if len(PodsWithIssues) > 0 {
-		for issue, podlist := range lsconf.ConfigIssueMapping {
-			fmt.Printf("\nConfig Issue: '%s'\n", issue)
-			fmt.Printf("Pods with issue %d\n", len(podlist))
-			fmt.Println("=============================")
-			for _, pod := range podlist {
-				fmt.Printf("  %s\n", pod.Name)
+	if watchMode {
+		abort("unable to watch config", auditor.Watch(watchPoll, nil, func(d *audit.Delta) {
+			if outputFormat == "json" {
+				d.WriteJSON(os.Stdout)
+			} else {
+				d.Write(os.Stdout)
 			}
+		}))
+	}
+	res, err := auditor.Run()
+	if err != nil {
+		abort("unable to load config file, aborting run", err)
+	}
 
+	if nagiosMode {
+		os.Exit(int(res.NagiosReport(os.Stdout)))
+	}
+	if fixMode {
+		err = res.FixConfigFile(os.Stdout, useConfig, dryRun, confirm)
+		if err != nil {
+			abort("unable to fix config file", err)
 		}
+		os.Exit(0)
 	}
+	if remediateMode {
+		err = res.Remediate(os.Stdout, applyRemediation, confirm)
+		if err != nil {
+			abort("remediation failed", err)
+		}
+		os.Exit(0)
+	}
+	if outputFormat == "json" {
+		err = res.WriteJSON(os.Stdout)
+		if err != nil {
+			abort("unable to write json report", err)
+		}
+	}
+	os.Exit(res.ExitStatus)
 }
 
-func FindDupeMasterIPs() {
-	log.Print("Looking for duplicated master IPs")
-	masterIPtoPodMapping = make(map[string]SentinelPodConfig)
-	for _, v := range lsconf.ManagedPodConfigs {
-		opod, dupe := masterIPtoPodMapping[v.IP]
-		if dupe {
-			fmt.Printf("Found Duplicate master! %s and %s share master IP %s", opod.Name, v.Name, v.IP)
-			lsconf.ConfigIssueMapping[DUPLICATEMASTERIP] = append(lsconf.ConfigIssueMapping[DUPLICATEMASTERIP], v)
-			lsconf.ConfigIssueMapping[DUPLICATEMASTERIP] = append(lsconf.ConfigIssueMapping[DUPLICATEMASTERIP], opod)
-			PodsWithIssues[DUPLICATEMASTERIP] = append(PodsWithIssues[DUPLICATEMASTERIP], v)
-			PodsWithIssues[DUPLICATEMASTERIP] = append(PodsWithIssues[DUPLICATEMASTERIP], opod)
+// auditConfigs audits several configs and reports on them together,
+// returning the exit status.
+func auditConfigs(paths []string, opts audit.Options) int {
+	var sources []audit.Source
+	for _, path := range paths {
+		sources = append(sources, audit.FileSource(path))
+	}
+	fr := audit.AuditConfigs(sources, opts)
 
-			// test v
-			_, err := client.DialWithConfig(&client.DialConfig{Address: fmt.Sprintf("%s:%d", v.IP, v.Port), Password: v.AuthToken})
-			if err != nil {
-				log.Printf("Pod %s could not auth to %s, recommend deleting this one.", v.Name, v.IP)
+	if nagiosMode {
+		return int(fr.NagiosReport(os.Stdout))
+	}
+	if fixMode || remediateMode {
+		for _, res := range fr.Results {
+			fmt.Printf("==> %s <==\n", res.Path)
+			if fixMode {
+				err := res.FixConfigFile(os.Stdout, res.Path, dryRun, confirm)
+				if err != nil {
+					abort("unable to fix config file", err)
+				}
 			} else {
-				// test opod
-				_, err := client.DialWithConfig(&client.DialConfig{Address: fmt.Sprintf("%s:%d", opod.IP, opod.Port), Password: opod.AuthToken})
+				err := res.Remediate(os.Stdout, applyRemediation, confirm)
 				if err != nil {
-					log.Printf("Pod %s could not auth to %s, recommend deleting this one.", opod.Name, opod.IP)
+					abort("remediation failed", err)
 				}
 			}
-
-		} else {
-			masterIPtoPodMapping[v.IP] = v
+			fmt.Println()
 		}
-	}
-
-}
-
-func FindDupeSlaveIPs() {
-	log.Print("Looking for duplicated slave IPs")
-	slaveIPtoPodMapping := make(map[string]SentinelPodConfig)
-	for _, v := range lsconf.ManagedPodConfigs {
-		for _, slave := range v.Slaves {
-			if opod, dupe := slaveIPtoPodMapping[slave]; dupe {
-				log.Printf("Found Duplicate slave! %s and %s share slave IP %s", opod.Name, v.Name, slave)
-				lsconf.ConfigIssueMapping[DUPLICATESLAVEIP] = append(lsconf.ConfigIssueMapping[DUPLICATESLAVEIP], v)
-				lsconf.ConfigIssueMapping[DUPLICATESLAVEIP] = append(lsconf.ConfigIssueMapping[DUPLICATESLAVEIP], opod)
-				PodsWithIssues[DUPLICATESLAVEIP] = append(PodsWithIssues[DUPLICATESLAVEIP], v)
-				PodsWithIssues[DUPLICATESLAVEIP] = append(PodsWithIssues[DUPLICATESLAVEIP], opod)
-			} else {
-				slaveIPtoPodMapping[slave] = v
-			}
-			if opod, dupe := masterIPtoPodMapping[slave]; dupe {
-				log.Printf("Found Duplicate slave/master! %s is master for %s and slave for %s", slave, opod.Name, v.Name)
-				lsconf.ConfigIssueMapping[DUPLICATESLAVEIP] = append(lsconf.ConfigIssueMapping[DUPLICATESLAVEIP], v)
-				lsconf.ConfigIssueMapping[DUPLICATESLAVEIP] = append(lsconf.ConfigIssueMapping[DUPLICATESLAVEIP], opod)
-				PodsWithIssues[DUPLICATESLAVEIP] = append(PodsWithIssues[DUPLICATESLAVEIP], v)
-				PodsWithIssues[DUPLICATESLAVEIP] = append(PodsWithIssues[DUPLICATESLAVEIP], opod)
-			} else {
-				slaveIPtoPodMapping[slave] = v
-			}
+		if len(fr.Failures) > 0 {
+			return audit.ExitAuditFailure
 		}
+		return 0
 	}
-
-}
-
-func main() {
-	flag.Parse()
-	log.Printf("Reports to run: %+v", reportFlag)
-	log.Print("Running sentinel config audit")
-	lsconf.ConfigIssueMapping = make(map[ConfigIssue][]SentinelPodConfig)
-	err := LoadSentinelConfigFile()
-	if err != nil {
-		log.Fatal("unable to laod config file, aborting run: ", err)
-	}
-	fmt.Printf("Configuration Audit Run for Sentinel '%s' at %s\n", lsconf.Name, time.Now())
-	fmt.Println()
-
-	for _, rep := range reportFlag {
-		switch rep {
-		case "baseconfig":
-			BaseConfigReport()
-
-		case "known-sentinels":
-			KnownSentinelsReport()
-
-		case "all", "":
-			BaseConfigReport()
-			KnownSentinelsReport()
-			FindDupeMasterIPs()
-			FindDupeSlaveIPs()
-			PodReport()
-
-		default:
-			fmt.Printf("Unknown report '%s'\n", rep)
-
+	if outputFormat == "json" {
+		err := fr.WriteJSON(os.Stdout)
+		if err != nil {
+			abort("unable to write json report", err)
 		}
+		return fr.ExitStatus
 	}
+	fmt.Println()
+	fr.WriteSummary(os.Stdout)
+	return fr.ExitStatus
 }
@@ -0,0 +1,472 @@
+// Package redistest provides a fake Redis or Sentinel server for tests. It
+// speaks enough RESP to answer the commands the audit sends, with replies
+// scripted by the test.
+package redistest
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Status is a simple string reply, such as OK or PONG.
+type Status string
+
+// Error is an error reply. It should start with an error code, e.g.
+// "ERR unknown command".
+type Error string
+
+// Server is a fake Redis or Sentinel listening on a free localhost port.
+// Replies are scripted with its methods, which may be called while clients
+// are connected.
+//
+// PING, AUTH, ACL WHOAMI, ACL GETUSER, INFO, CONFIG GET and SENTINEL MASTERS, MASTER, SLAVES, REPLICAS
+// and SENTINELS are answered from the scripted state. Any command may be
+// given a fixed reply with Reply; anything else gets an error.
+type Server struct {
+	// Addr is the host:port the server listens on.
+	Addr string
+
+	ln       net.Listener
+	mu       sync.Mutex
+	password string
+	users    map[string]aclUser
+	info     map[string]infoSection
+	config   map[string]string
+	masters  []map[string]string
+	slaves   map[string][]map[string]string
+	peers    map[string][]map[string]string
+	replies  map[string]interface{}
+	commands [][]string
+	conns    map[net.Conn]bool
+	wg       sync.WaitGroup
+}
+
+// NewServer starts a server on a free localhost port.
+func NewServer() (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return serve(ln), nil
+}
+
+func serve(ln net.Listener) *Server {
+	s := &Server{
+		Addr:    ln.Addr().String(),
+		ln:      ln,
+		users:   make(map[string]aclUser),
+		info:    make(map[string]infoSection),
+		config:  make(map[string]string),
+		slaves:  make(map[string][]map[string]string),
+		peers:   make(map[string][]map[string]string),
+		replies: make(map[string]interface{}),
+		conns:   make(map[net.Conn]bool),
+	}
+	s.wg.Add(1)
+	go s.serve()
+	return s
+}
+
+// Host returns the host the server listens on.
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr)
+	return host
+}
+
+// Port returns the port the server listens on.
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr)
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// Close stops the server and drops every client connection.
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return err
+}
+
+// SetPassword makes the server require AUTH with password before any other
+// command. An empty password turns authentication off.
+func (s *Server) SetPassword(password string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.password = password
+}
+
+// aclUser is an ACL user as ACL GETUSER reports it.
+type aclUser struct {
+	commands string
+	channels string
+}
+
+// AddUser adds an ACL user which may AUTH with the server's password. The
+// commands and channels rules are those ACL GETUSER reports for the user,
+// e.g. AddUser("sentinel", "-@all +ping +info", "&__sentinel__:hello").
+func (s *Server) AddUser(name, commands, channels string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[name] = aclUser{commands, channels}
+}
+
+// infoSection is a section of the INFO reply: its title, as Redis writes it
+// in the section header, and its field lines.
+type infoSection struct {
+	title  string
+	fields string
+}
+
+// SetInfo sets the fields INFO reports for section, named as in the section
+// header, e.g. SetInfo("Replication", "role", "master", "connected_slaves", "0").
+func (s *Server) SetInfo(section string, fields ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var b bytes.Buffer
+	for i := 0; i+1 < len(fields); i += 2 {
+		fmt.Fprintf(&b, "%s:%s\r\n", fields[i], fields[i+1])
+	}
+	s.info[strings.ToLower(section)] = infoSection{section, b.String()}
+}
+
+// SetConfig sets the value CONFIG GET returns for parameter.
+func (s *Server) SetConfig(parameter, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.config[parameter] = value
+}
+
+// AddMaster adds a pod to those SENTINEL MASTERS and SENTINEL MASTER report.
+// The fields are as Sentinel reports them, e.g. "name", "ip", "port",
+// "quorum", "flags" and "info-refresh".
+func (s *Server) AddMaster(fields map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.masters = append(s.masters, fields)
+}
+
+// AddSlave adds a replica to those SENTINEL SLAVES reports for pod.
+func (s *Server) AddSlave(pod string, fields map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.slaves[pod] = append(s.slaves[pod], fields)
+}
+
+// AddSentinel adds a sentinel to those SENTINEL SENTINELS reports for pod.
+func (s *Server) AddSentinel(pod string, fields map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers[pod] = append(s.peers[pod], fields)
+}
+
+// Reply scripts the reply to a command, given as its name and, for container
+// commands, its subcommand, e.g. "SENTINEL RESET". A string is sent as a bulk
+// string, an int as an integer, a Status or Error as such, nil as a null bulk
+// string and a slice as an array of any of these.
+func (s *Server) Reply(command string, reply interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.replies[strings.ToUpper(command)] = reply
+}
+
+// Commands returns every command received so far, AUTH included.
+func (s *Server) Commands() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string(nil), s.commands...)
+}
+
+func (s *Server) serve() {
+	defer s.wg.Done()
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+		s.wg.Add(1)
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer s.wg.Done()
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	user := ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		var reply interface{}
+		reply, user = s.dispatch(args, user)
+		writeReply(w, reply)
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// dispatch works out the reply to a command sent by a connection
+// authenticated as user, which is empty before a successful AUTH. It returns
+// the user the connection is authenticated as afterwards.
+func (s *Server) dispatch(args []string, user string) (interface{}, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.commands = append(s.commands, args)
+	name := strings.ToUpper(args[0])
+	if len(args) > 1 {
+		if reply, ok := s.replies[name+" "+strings.ToUpper(args[1])]; ok {
+			return reply, user
+		}
+	}
+	if reply, ok := s.replies[name]; ok {
+		return reply, user
+	}
+
+	if name == "AUTH" {
+		name := "default"
+		if len(args) > 2 {
+			name = args[1]
+		}
+		_, known := s.users[name]
+		switch {
+		case s.password == "":
+			return Error("ERR Client sent AUTH, but no password is set"), user
+		case args[len(args)-1] != s.password || (name != "default" && !known):
+			return Error("WRONGPASS invalid username-password pair or user is disabled."), ""
+		}
+		return Status("OK"), name
+	}
+	if s.password != "" && user == "" {
+		return Error("NOAUTH Authentication required."), ""
+	}
+
+	switch name {
+	case "PING":
+		return Status("PONG"), user
+	case "INFO":
+		return s.infoReply(args[1:]), user
+	case "ACL":
+		if reply, ok := s.aclReply(args[1:], user); ok {
+			return reply, user
+		}
+	case "CONFIG":
+		if len(args) == 3 && strings.ToUpper(args[1]) == "GET" {
+			var reply []interface{}
+			for _, k := range sortedKeys(s.config) {
+				if k == args[2] || args[2] == "*" {
+					reply = append(reply, k, s.config[k])
+				}
+			}
+			return reply, user
+		}
+	case "SENTINEL":
+		if reply, ok := s.sentinelReply(args[1:]); ok {
+			return reply, user
+		}
+	}
+	return Error(fmt.Sprintf("ERR unknown command '%s'", strings.Join(args, " "))), user
+}
+
+func (s *Server) aclReply(args []string, user string) (interface{}, bool) {
+	if len(args) == 0 {
+		return nil, false
+	}
+	switch strings.ToUpper(args[0]) {
+	case "WHOAMI":
+		if user == "" {
+			user = "default"
+		}
+		return user, true
+	case "GETUSER":
+		if len(args) < 2 {
+			return nil, false
+		}
+		u, ok := s.users[args[1]]
+		if !ok {
+			return nil, true
+		}
+		return []interface{}{
+			"flags", []string{"on"},
+			"passwords", []string{},
+			"commands", u.commands,
+			"keys", "",
+			"channels", u.channels,
+		}, true
+	}
+	return nil, false
+}
+
+func (s *Server) infoReply(sections []string) interface{} {
+	var names []string
+	if len(sections) == 0 || strings.EqualFold(sections[0], "all") || strings.EqualFold(sections[0], "default") {
+		for name := range s.info {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		names = []string{strings.ToLower(sections[0])}
+	}
+	var b bytes.Buffer
+	for _, name := range names {
+		section, ok := s.info[name]
+		if !ok {
+			continue
+		}
+		fmt.Fprintf(&b, "# %s\r\n%s\r\n", section.title, section.fields)
+	}
+	return b.String()
+}
+
+func (s *Server) sentinelReply(args []string) (interface{}, bool) {
+	if len(args) == 0 {
+		return nil, false
+	}
+	switch strings.ToUpper(args[0]) {
+	case "MASTERS":
+		return hashes(s.masters), true
+	case "MASTER":
+		if len(args) < 2 {
+			return nil, false
+		}
+		for _, m := range s.masters {
+			if m["name"] == args[1] {
+				return hash(m), true
+			}
+		}
+		return Error("ERR No such master with that name"), true
+	case "SLAVES", "REPLICAS":
+		if len(args) < 2 {
+			return nil, false
+		}
+		return hashes(s.slaves[args[1]]), true
+	case "SENTINELS":
+		if len(args) < 2 {
+			return nil, false
+		}
+		return hashes(s.peers[args[1]]), true
+	}
+	return nil, false
+}
+
+// hash flattens fields into the key, value array Sentinel replies with.
+func hash(fields map[string]string) []interface{} {
+	var reply []interface{}
+	for _, k := range sortedKeys(fields) {
+		reply = append(reply, k, fields[k])
+	}
+	return reply
+}
+
+func hashes(list []map[string]string) []interface{} {
+	reply := []interface{}{}
+	for _, fields := range list {
+		reply = append(reply, hash(fields))
+	}
+	return reply
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// readCommand reads a command sent as a RESP array of bulk strings, or as an
+// inline command.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil {
+		return nil, fmt.Errorf("bad array length %q", line)
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("expected bulk string, got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, fmt.Errorf("bad bulk length %q", line)
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeReply(w *bufio.Writer, reply interface{}) {
+	switch v := reply.(type) {
+	case nil:
+		w.WriteString("$-1\r\n")
+	case Status:
+		fmt.Fprintf(w, "+%s\r\n", v)
+	case Error:
+		fmt.Fprintf(w, "-%s\r\n", v)
+	case int:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case []string:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	default:
+		fmt.Fprintf(w, "-ERR redistest can not encode %T\r\n", reply)
+	}
+}
@@ -0,0 +1,97 @@
+package redistest
+
+import (
+	"testing"
+
+	"github.com/therealbill/libredis/client"
+)
+
+func dial(t *testing.T, s *Server) *client.Redis {
+	conn, err := client.DialWithConfig(&client.DialConfig{Address: s.Addr})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+func TestSentinelReplies(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.AddMaster(map[string]string{"name": "mymaster", "ip": "127.0.0.1", "port": "6379", "quorum": "2"})
+	s.AddSlave("mymaster", map[string]string{"name": "127.0.0.1:6380", "ip": "127.0.0.1", "port": "6380", "flags": "slave"})
+	s.AddSentinel("mymaster", map[string]string{"name": "127.0.0.1:26380", "ip": "127.0.0.1", "port": "26380", "runid": "1111"})
+	conn := dial(t, s)
+	defer conn.ClosePool()
+
+	slaves, err := conn.SentinelSlaves("mymaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slaves) != 1 || slaves[0].Host != "127.0.0.1" || slaves[0].Port != 6380 || slaves[0].Flags != "slave" {
+		t.Errorf("SENTINEL SLAVES: got %+v, want the one slave added", slaves)
+	}
+	rp, err := conn.ExecuteCommand("SENTINEL", "REPLICAS", "mymaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rp.Multi) != 1 {
+		t.Errorf("SENTINEL REPLICAS: got %d replicas, want 1", len(rp.Multi))
+	}
+	sentinels, err := conn.SentinelSentinels("mymaster")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sentinels) != 1 || sentinels[0].Port != 26380 || sentinels[0].Runid != "1111" {
+		t.Errorf("SENTINEL SENTINELS: got %+v, want the one sentinel added", sentinels)
+	}
+	if slaves, err := conn.SentinelSlaves("other"); err != nil || len(slaves) != 0 {
+		t.Errorf("SENTINEL SLAVES of an unknown pod: got %+v, %v, want none", slaves, err)
+	}
+	if sentinels, err := conn.SentinelSentinels("other"); err != nil || len(sentinels) != 0 {
+		t.Errorf("SENTINEL SENTINELS of an unknown pod: got %+v, %v, want none", sentinels, err)
+	}
+}
+
+func TestConfigGet(t *testing.T) {
+	s, err := NewServer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.SetConfig("maxmemory", "1048576")
+	s.SetConfig("maxmemory-policy", "noeviction")
+	conn := dial(t, s)
+	defer conn.ClosePool()
+
+	tests := []struct {
+		parameter string
+		want      map[string]string
+	}{
+		{"maxmemory", map[string]string{"maxmemory": "1048576"}},
+		{"*", map[string]string{"maxmemory": "1048576", "maxmemory-policy": "noeviction"}},
+		{"appendonly", map[string]string{}},
+	}
+	for _, tt := range tests {
+		got, err := conn.ConfigGet(tt.parameter)
+		if err != nil {
+			t.Errorf("CONFIG GET %s: %s", tt.parameter, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("CONFIG GET %s: got %v, want %v", tt.parameter, got, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if got[k] != v {
+				t.Errorf("CONFIG GET %s: got %v, want %v", tt.parameter, got, tt.want)
+			}
+		}
+	}
+	commands := s.Commands()
+	if len(commands) != len(tests) || commands[0][0] != "CONFIG" || commands[0][2] != "maxmemory" {
+		t.Errorf("got commands %q, want the %d CONFIG GETs", commands, len(tests))
+	}
+}
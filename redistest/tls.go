@@ -0,0 +1,72 @@
+package redistest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+)
+
+var (
+	certOnce sync.Once
+	cert     tls.Certificate
+	certPEM  []byte
+	certErr  error
+)
+
+// loadCert makes the self-signed certificate for 127.0.0.1 which every TLS
+// server presents.
+func loadCert() {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		certErr = err
+		return
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "redistest"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		certErr = err
+		return
+	}
+	cert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// CACert returns the PEM certificate TLS servers present, for use as the CA
+// bundle of their clients.
+func CACert() ([]byte, error) {
+	certOnce.Do(loadCert)
+	return certPEM, certErr
+}
+
+// NewTLSServer starts a server which only accepts TLS connections on a free
+// localhost port. Its certificate is signed by CACert.
+func NewTLSServer() (*Server, error) {
+	certOnce.Do(loadCert)
+	if certErr != nil {
+		return nil, certErr
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{cert}})
+	if err != nil {
+		return nil, err
+	}
+	return serve(ln), nil
+}
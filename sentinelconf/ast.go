@@ -0,0 +1,192 @@
+package sentinelconf
+
+import "fmt"
+
+// Pos is where a directive was found.
+type Pos struct {
+	File string
+	Line int
+}
+
+func (p Pos) String() string {
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// Position returns p, so that every node which embeds a Pos is a Node.
+func (p Pos) Position() Pos {
+	return p
+}
+
+// Node is a single directive parsed from a config file.
+type Node interface {
+	Position() Pos
+}
+
+// PodNode is a directive which applies to a single pod.
+type PodNode interface {
+	Node
+	PodName() string
+}
+
+// Port is the "port" directive.
+type Port struct {
+	Pos
+	Port int
+}
+
+// Bind is the "bind" directive.
+type Bind struct {
+	Pos
+	Addresses []string
+}
+
+// Dir is the "dir" directive.
+type Dir struct {
+	Pos
+	Path string
+}
+
+// Monitor is the "sentinel monitor" directive which defines a pod.
+type Monitor struct {
+	Pos
+	Pod    string
+	Host   string
+	Port   int
+	Quorum int
+}
+
+// AuthPass is the "sentinel auth-pass" directive.
+type AuthPass struct {
+	Pos
+	Pod      string
+	Password string
+}
+
+// KnownSentinel is the "sentinel known-sentinel" directive.
+type KnownSentinel struct {
+	Pos
+	Pod   string
+	Host  string
+	Port  int
+	RunID string
+}
+
+// AuthUser is the "sentinel auth-user" directive giving the ACL user to
+// authenticate to a pod's instances as.
+type AuthUser struct {
+	Pos
+	Pod  string
+	User string
+}
+
+// KnownSlave is the "sentinel known-slave" directive, or "sentinel
+// known-replica" as newer sentinels write it.
+type KnownSlave struct {
+	Pos
+	Pod  string
+	Host string
+	Port int
+}
+
+// RenameCommand is the "sentinel rename-command" directive.
+type RenameCommand struct {
+	Pos
+	Pod       string
+	Command   string
+	RenamedTo string
+}
+
+// PodInt is a "sentinel" directive which sets a numeric value for a pod, such
+// as failover-timeout or config-epoch.
+type PodInt struct {
+	Pos
+	Name  string
+	Pod   string
+	Value int
+}
+
+// PodOption is any other "sentinel" directive which applies to a single pod,
+// such as notification-script.
+type PodOption struct {
+	Pos
+	Name string
+	Pod  string
+	Args []string
+}
+
+func (n *Monitor) PodName() string       { return n.Pod }
+func (n *AuthPass) PodName() string      { return n.Pod }
+func (n *AuthUser) PodName() string      { return n.Pod }
+func (n *KnownSentinel) PodName() string { return n.Pod }
+func (n *KnownSlave) PodName() string    { return n.Pod }
+func (n *RenameCommand) PodName() string { return n.Pod }
+func (n *PodInt) PodName() string        { return n.Pod }
+func (n *PodOption) PodName() string     { return n.Pod }
+
+// SentinelSetting is a "sentinel" directive which sets a value for the
+// sentinel itself, such as myid or announce-ip.
+type SentinelSetting struct {
+	Pos
+	Name  string
+	Value string
+}
+
+// SentinelFlag is a yes/no "sentinel" directive for the sentinel itself, such
+// as resolve-hostnames.
+type SentinelFlag struct {
+	Pos
+	Name    string
+	Enabled bool
+}
+
+// Option is any other directive, kept verbatim. Sentinel directives which do
+// not apply to a pod, such as "sentinel current-epoch", have the Name
+// "sentinel" and the sentinel directive as their first argument.
+type Option struct {
+	Pos
+	Name string
+	Args []string
+}
+
+// Severity is how serious a Diagnostic is.
+type Severity int
+
+const (
+	// Warning diagnostics are for lines which were understood but are
+	// probably not what was intended.
+	Warning Severity = iota
+	// Error diagnostics are for lines which could not be parsed and were
+	// dropped.
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "error"
+	}
+	return "warning"
+}
+
+// MarshalText encodes the severity by name.
+func (s Severity) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Diagnostic is a problem found with a line of a config file.
+type Diagnostic struct {
+	Pos      Pos
+	Severity Severity
+	Message  string
+	Line     string
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("%s: %s: %s", d.Pos, d.Severity, d.Message)
+}
+
+// File is a parsed config file.
+type File struct {
+	Path        string
+	Nodes       []Node
+	Diagnostics []Diagnostic
+}
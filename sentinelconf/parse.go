@@ -0,0 +1,246 @@
+// Package sentinelconf parses Redis Sentinel config files into typed
+// directives, reporting malformed lines as diagnostics rather than failing.
+package sentinelconf
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ParseFile reads and parses the config file at path. An error is only
+// returned if the file can not be read; problems with its contents are
+// reported in the Diagnostics of the returned File.
+func ParseFile(path string) (*File, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return Parse(path, f)
+}
+
+// Parse parses a config file read from r. path is only used for positions.
+// Blank lines and lines whose first non-blank character is '#' are skipped,
+// as Redis skips them.
+func Parse(path string, r io.Reader) (*File, error) {
+	file := &File{Path: path}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+		pos := Pos{File: path, Line: lineno}
+		if trimmed := strings.Trim(line, " \t\r\n"); trimmed == "" || trimmed[0] == '#' {
+			continue
+		}
+		args, err := SplitArgs(line)
+		if err != nil {
+			file.errorf(pos, line, "%s", err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		node, err := parseDirective(pos, args)
+		if err != nil {
+			file.errorf(pos, line, "%s", err)
+			continue
+		}
+		file.Nodes = append(file.Nodes, node)
+	}
+	if err := scanner.Err(); err != nil {
+		return file, err
+	}
+	return file, nil
+}
+
+func (f *File) errorf(pos Pos, line string, format string, args ...interface{}) {
+	f.Diagnostics = append(f.Diagnostics, Diagnostic{pos, Error, fmt.Sprintf(format, args...), line})
+}
+
+// Warnf records a warning about a line. It is for callers which find
+// problems in nodes that parsed cleanly.
+func (f *File) Warnf(pos Pos, format string, args ...interface{}) {
+	f.Diagnostics = append(f.Diagnostics, Diagnostic{pos, Warning, fmt.Sprintf(format, args...), ""})
+}
+
+func wantArgs(name string, args []string, min int) error {
+	if len(args) < min {
+		return fmt.Errorf("'%s' needs %d arguments, got %d", name, min, len(args))
+	}
+	return nil
+}
+
+func atoi(what, s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s'", what, s)
+	}
+	return n, nil
+}
+
+func parseDirective(pos Pos, args []string) (Node, error) {
+	name := strings.ToLower(args[0])
+	args = args[1:]
+	switch name {
+	case "port":
+		if err := wantArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		port, err := atoi("port", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return &Port{pos, port}, nil
+	case "bind":
+		if err := wantArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		return &Bind{pos, args}, nil
+	case "dir":
+		if err := wantArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		return &Dir{pos, args[0]}, nil
+	case "sentinel":
+		if err := wantArgs(name, args, 1); err != nil {
+			return nil, err
+		}
+		return parseSentinelDirective(pos, strings.ToLower(args[0]), args[1:])
+	}
+	return &Option{pos, name, args}, nil
+}
+
+// podInts are the sentinel directives which set a numeric value for a pod.
+var podInts = map[string]bool{
+	"config-epoch":            true,
+	"leader-epoch":            true,
+	"down-after-milliseconds": true,
+	"failover-timeout":        true,
+	"parallel-syncs":          true,
+}
+
+// podOptions are the other sentinel directives whose first argument is a pod
+// name.
+var podOptions = map[string]bool{
+	"notification-script":    true,
+	"client-reconfig-script": true,
+}
+
+// sentinelSettings are the sentinel directives which set a single value for
+// the sentinel itself. The value of those mapped to true must be numeric.
+var sentinelSettings = map[string]bool{
+	"myid":          false,
+	"sentinel-user": false,
+	"sentinel-pass": false,
+	"announce-ip":   false,
+	"announce-port": true,
+}
+
+// sentinelFlags are the yes/no sentinel directives for the sentinel itself.
+var sentinelFlags = map[string]bool{
+	"resolve-hostnames":     true,
+	"announce-hostnames":    true,
+	"deny-scripts-reconfig": true,
+}
+
+func parseSentinelDirective(pos Pos, name string, args []string) (Node, error) {
+	full := "sentinel " + name
+	switch name {
+	case "monitor":
+		if err := wantArgs(full, args, 4); err != nil {
+			return nil, err
+		}
+		port, err := atoi("port", args[2])
+		if err != nil {
+			return nil, err
+		}
+		quorum, err := atoi("quorum", args[3])
+		if err != nil {
+			return nil, err
+		}
+		return &Monitor{pos, args[0], args[1], port, quorum}, nil
+	case "auth-pass":
+		if err := wantArgs(full, args, 2); err != nil {
+			return nil, err
+		}
+		return &AuthPass{pos, args[0], args[1]}, nil
+	case "known-sentinel":
+		if err := wantArgs(full, args, 3); err != nil {
+			return nil, err
+		}
+		port, err := atoi("port", args[2])
+		if err != nil {
+			return nil, err
+		}
+		ks := &KnownSentinel{Pos: pos, Pod: args[0], Host: args[1], Port: port}
+		if len(args) > 3 {
+			ks.RunID = args[3]
+		}
+		return ks, nil
+	case "auth-user":
+		if err := wantArgs(full, args, 2); err != nil {
+			return nil, err
+		}
+		return &AuthUser{pos, args[0], args[1]}, nil
+	case "rename-command":
+		if err := wantArgs(full, args, 3); err != nil {
+			return nil, err
+		}
+		return &RenameCommand{pos, args[0], args[1], args[2]}, nil
+	case "known-slave", "known-replica":
+		if err := wantArgs(full, args, 3); err != nil {
+			return nil, err
+		}
+		port, err := atoi("port", args[2])
+		if err != nil {
+			return nil, err
+		}
+		return &KnownSlave{pos, args[0], args[1], port}, nil
+	}
+	if podInts[name] {
+		if err := wantArgs(full, args, 2); err != nil {
+			return nil, err
+		}
+		value, err := atoi(name, args[1])
+		if err != nil {
+			return nil, err
+		}
+		return &PodInt{pos, name, args[0], value}, nil
+	}
+	if podOptions[name] {
+		if err := wantArgs(full, args, 2); err != nil {
+			return nil, err
+		}
+		return &PodOption{pos, name, args[0], args[1:]}, nil
+	}
+	if numeric, ok := sentinelSettings[name]; ok {
+		if err := wantArgs(full, args, 1); err != nil {
+			return nil, err
+		}
+		if numeric {
+			if _, err := atoi(name, args[0]); err != nil {
+				return nil, err
+			}
+		}
+		return &SentinelSetting{pos, name, args[0]}, nil
+	}
+	if sentinelFlags[name] {
+		if err := wantArgs(full, args, 1); err != nil {
+			return nil, err
+		}
+		switch strings.ToLower(args[0]) {
+		case "yes":
+			return &SentinelFlag{pos, name, true}, nil
+		case "no":
+			return &SentinelFlag{pos, name, false}, nil
+		}
+		return nil, fmt.Errorf("'%s' must be yes or no, got '%s'", full, args[0])
+	}
+	return &Option{pos, "sentinel", append([]string{name}, args...)}, nil
+}
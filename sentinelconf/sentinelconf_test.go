@@ -0,0 +1,103 @@
+package sentinelconf
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSplitArgs(t *testing.T) {
+	tests := []struct {
+		line string
+		want []string
+		err  string
+	}{
+		{"", nil, ""},
+		{"  \t ", nil, ""},
+		{"port 26379", []string{"port", "26379"}, ""},
+		{"\tsentinel\tmonitor  mymaster\t127.0.0.1 6379 2\r", []string{"sentinel", "monitor", "mymaster", "127.0.0.1", "6379", "2"}, ""},
+		{`sentinel auth-pass mymaster "two words"`, []string{"sentinel", "auth-pass", "mymaster", "two words"}, ""},
+		{`a "" b`, []string{"a", "", "b"}, ""},
+		{`a "\n\r\t\b\a\\\"x"`, []string{"a", "\n\r\t\b\a\\\"x"}, ""},
+		{`a "\x41\x7a\x00"`, []string{"a", "Az\x00"}, ""},
+		{`a "\xZZ" "\x4"`, []string{"a", "xZZ", "x4"}, ""},
+		{`a 'it\'s "raw" \n'`, []string{"a", `it's "raw" \n`}, ""},
+		{`a"b"`, []string{`ab`}, ""},
+		{`a "b`, nil, "unbalanced double quotes"},
+		{`a 'b`, nil, "unbalanced single quotes"},
+		{`a "b"c`, nil, "closing quote must be followed by a space"},
+		{`a 'b'c`, nil, "closing quote must be followed by a space"},
+		{"sentinel auth-pass mymaster #abc", []string{"sentinel", "auth-pass", "mymaster", "#abc"}, ""},
+		{"sentinel auth-pass mymaster ab#c # not a comment", []string{"sentinel", "auth-pass", "mymaster", "ab#c", "#", "not", "a", "comment"}, ""},
+		{"# comment", []string{"#", "comment"}, ""},
+	}
+	for _, tt := range tests {
+		got, err := SplitArgs(tt.line)
+		if tt.err != "" {
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("SplitArgs(%q): got error %v, want %q", tt.line, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("SplitArgs(%q): %s", tt.line, err)
+			continue
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.want) {
+			t.Errorf("SplitArgs(%q): got %q, want %q", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		conf  string
+		nodes []string
+		diags []string
+	}{
+		{"# comment\n\n   # indented comment\n\t\nport 26379\n", []string{"*sentinelconf.Port"}, nil},
+		{"sentinel auth-pass mymaster #abc\n", []string{"*sentinelconf.AuthPass"}, nil},
+		{"port\nport 26379x\n", nil, []string{
+			"test.conf:1: error: 'port' needs 1 arguments, got 0",
+			"test.conf:2: error: invalid port '26379x'",
+		}},
+		{"sentinel monitor mymaster 127.0.0.1 6379\n", nil, []string{
+			"test.conf:1: error: 'sentinel monitor' needs 4 arguments, got 3",
+		}},
+		{"sentinel known-sentinel mymaster 10.0.0.1 x\nsentinel known-replica mymaster 10.0.0.2 6379\n", []string{"*sentinelconf.KnownSlave"}, []string{
+			"test.conf:1: error: invalid port 'x'",
+		}},
+		{"sentinel down-after-milliseconds mymaster soon\nsentinel parallel-syncs mymaster 1\n", []string{"*sentinelconf.PodInt"}, []string{
+			"test.conf:1: error: invalid down-after-milliseconds 'soon'",
+		}},
+		{"sentinel resolve-hostnames maybe\nsentinel announce-port x\n", nil, []string{
+			"test.conf:1: error: 'sentinel resolve-hostnames' must be yes or no, got 'maybe'",
+			"test.conf:2: error: invalid announce-port 'x'",
+		}},
+		{"sentinel auth-pass mymaster \"unbalanced\nsentinel\n", nil, []string{
+			"test.conf:1: error: unbalanced double quotes",
+			"test.conf:2: error: 'sentinel' needs 1 arguments, got 0",
+		}},
+		{"sentinel deny-scripts-reconfig yes\nappendonly no\n", []string{"*sentinelconf.SentinelFlag", "*sentinelconf.Option"}, nil},
+	}
+	for _, tt := range tests {
+		file, err := Parse("test.conf", strings.NewReader(tt.conf))
+		if err != nil {
+			t.Fatalf("%q: %s", tt.conf, err)
+		}
+		var nodes []string
+		for _, node := range file.Nodes {
+			nodes = append(nodes, fmt.Sprintf("%T", node))
+		}
+		if fmt.Sprint(nodes) != fmt.Sprint(tt.nodes) {
+			t.Errorf("%q: got nodes %v, want %v", tt.conf, nodes, tt.nodes)
+		}
+		var diags []string
+		for _, d := range file.Diagnostics {
+			diags = append(diags, d.Error())
+		}
+		if strings.Join(diags, "\n") != strings.Join(tt.diags, "\n") {
+			t.Errorf("%q: got diagnostics\n%s\nwant\n%s", tt.conf, strings.Join(diags, "\n"), strings.Join(tt.diags, "\n"))
+		}
+	}
+}
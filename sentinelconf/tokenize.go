@@ -0,0 +1,103 @@
+package sentinelconf
+
+import (
+	"errors"
+	"strconv"
+)
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+func isHexDigit(c byte) bool {
+	return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+}
+
+// SplitArgs splits a config line into arguments the way Redis does when it
+// loads its config (sdssplitargs). Arguments are separated by whitespace and
+// may be double quoted, supporting \n \r \t \b \a \\ \" and \xHH escapes, or
+// single quoted, supporting \'. A closing quote must be followed by
+// whitespace or the end of the line.
+//
+// Comments are not recognized here: as in Redis, only a line whose first
+// non-blank character is '#' is a comment, and Parse skips those. A '#'
+// starting any later argument, such as a password, is part of it.
+func SplitArgs(line string) ([]string, error) {
+	var args []string
+	i := 0
+	for {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		if i >= len(line) {
+			return args, nil
+		}
+		var current []byte
+		inq, insq, done := false, false, false
+		for !done {
+			if inq {
+				switch {
+				case i >= len(line):
+					return nil, errors.New("unbalanced double quotes")
+				case line[i] == '\\' && i+3 < len(line) && line[i+1] == 'x' && isHexDigit(line[i+2]) && isHexDigit(line[i+3]):
+					b, _ := strconv.ParseUint(line[i+2:i+4], 16, 8)
+					current = append(current, byte(b))
+					i += 3
+				case line[i] == '\\' && i+1 < len(line):
+					i++
+					switch line[i] {
+					case 'n':
+						current = append(current, '\n')
+					case 'r':
+						current = append(current, '\r')
+					case 't':
+						current = append(current, '\t')
+					case 'b':
+						current = append(current, '\b')
+					case 'a':
+						current = append(current, '\a')
+					default:
+						current = append(current, line[i])
+					}
+				case line[i] == '"':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errors.New("closing quote must be followed by a space")
+					}
+					done = true
+				default:
+					current = append(current, line[i])
+				}
+			} else if insq {
+				switch {
+				case i >= len(line):
+					return nil, errors.New("unbalanced single quotes")
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == '\'':
+					i++
+					current = append(current, '\'')
+				case line[i] == '\'':
+					if i+1 < len(line) && !isSpace(line[i+1]) {
+						return nil, errors.New("closing quote must be followed by a space")
+					}
+					done = true
+				default:
+					current = append(current, line[i])
+				}
+			} else {
+				switch {
+				case i >= len(line) || isSpace(line[i]):
+					done = true
+				case line[i] == '"':
+					inq = true
+				case line[i] == '\'':
+					insq = true
+				default:
+					current = append(current, line[i])
+				}
+			}
+			if i < len(line) {
+				i++
+			}
+		}
+		args = append(args, string(current))
+	}
+}